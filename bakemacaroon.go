@@ -0,0 +1,166 @@
+package lndmon
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightninglabs/lndmon/collectors"
+	"github.com/lightningnetwork/lnd/lnrpc"
+)
+
+// bakeMacaroonCommand bakes a macaroon that only has the permissions lndmon
+// needs to run the collectors enabled in its configuration.
+type bakeMacaroonCommand struct {
+	// AdminMacaroonPath is the path to the macaroon we bake lndmon's
+	// macaroon with.
+	AdminMacaroonPath string `long:"adminmacaroonpath" description:"Path to a macaroon that is permitted to bake macaroons, only used to bake lndmon's macaroon (default: admin.macaroon in the lnd macaroon dir)"`
+
+	// MacaroonName is the name we save the baked macaroon under in the
+	// macaroon dir.
+	MacaroonName string `long:"macaroonname" description:"The name to save the baked macaroon under in the lnd macaroon dir"`
+
+	// Force overwrites an existing macaroon.
+	Force bool `long:"force" description:"Overwrite an existing macaroon with the same name"`
+}
+
+// Execute bakes lndmon's macaroon.
+//
+// NOTE: Part of the flags.Commander interface.
+func (b *bakeMacaroonCommand) Execute(_ []string) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	// We save the macaroon in the macaroon dir, so its name must not be
+	// empty or a path.
+	if b.MacaroonName == "" ||
+		filepath.Base(b.MacaroonName) != b.MacaroonName {
+
+		return fmt.Errorf("invalid macaroon name %q, must be a file "+
+			"name in the macaroon dir", b.MacaroonName)
+	}
+
+	if err := collectors.InitLogging(cfg.Prometheus); err != nil {
+		return err
+	}
+
+	monitoringCfg, err := cfg.monitoringConfig()
+	if err != nil {
+		return err
+	}
+
+	conn, err := cfg.Lnd.connection()
+	if err != nil {
+		return err
+	}
+
+	macaroonPath := filepath.Join(cfg.Lnd.MacaroonDir, b.MacaroonName)
+	if !b.Force {
+		_, err := os.Stat(macaroonPath)
+		switch {
+		case err == nil:
+			return fmt.Errorf("macaroon %v already exists, use "+
+				"--force to overwrite it", macaroonPath)
+
+		case !errors.Is(err, os.ErrNotExist):
+			return err
+		}
+	}
+
+	adminMacaroonPath := b.AdminMacaroonPath
+	if adminMacaroonPath == "" {
+		adminMacaroonPath = filepath.Join(
+			cfg.Lnd.MacaroonDir, defaultAdminMacaroon,
+		)
+	}
+
+	options := []lndclient.BasicClientOption{
+		lndclient.MacFilename(filepath.Base(adminMacaroonPath)),
+		lndclient.TLSData(conn.tlsData),
+	}
+	if conn.systemCert {
+		options = append(options, lndclient.SystemCerts())
+	}
+
+	client, err := lndclient.NewBasicClient(
+		conn.host, conn.tlsPath, filepath.Dir(adminMacaroonPath),
+		cfg.Lnd.Network, options...,
+	)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(
+		context.Background(), cfg.Lnd.RPCTimeout,
+	)
+	defer cancel()
+
+	resp, err := client.ListPermissions(
+		ctx, &lnrpc.ListPermissionsRequest{},
+	)
+	if err != nil {
+		return fmt.Errorf("unable to list permissions: %v", err)
+	}
+
+	methodPermissions := make(map[string][]lndclient.MacaroonPermission)
+	for method, list := range resp.MethodPermissions {
+		for _, permission := range list.Permissions {
+			methodPermissions[method] = append(
+				methodPermissions[method],
+				lndclient.MacaroonPermission{
+					Entity: permission.Entity,
+					Action: permission.Action,
+				},
+			)
+		}
+	}
+
+	permissions := collectors.RequiredPermissions(
+		methodPermissions, monitoringCfg, !cfg.Permissions.SkipCheck,
+	)
+
+	var (
+		names          = make([]string, len(permissions))
+		rpcPermissions = make(
+			[]*lnrpc.MacaroonPermission, len(permissions),
+		)
+	)
+	for i, permission := range permissions {
+		names[i] = permission.String()
+		rpcPermissions[i] = &lnrpc.MacaroonPermission{
+			Entity: permission.Entity,
+			Action: permission.Action,
+		}
+	}
+
+	collectors.Logger.Infof("Baking macaroon with permissions: %v",
+		strings.Join(names, ", "))
+
+	bakeResp, err := client.BakeMacaroon(ctx, &lnrpc.BakeMacaroonRequest{
+		Permissions: rpcPermissions,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to bake macaroon: %v", err)
+	}
+
+	macaroon, err := hex.DecodeString(bakeResp.Macaroon)
+	if err != nil {
+		return fmt.Errorf("invalid macaroon: %v", err)
+	}
+
+	if err := os.WriteFile(macaroonPath, macaroon, 0600); err != nil {
+		return err
+	}
+
+	collectors.Logger.Infof("Saved macaroon to %v, start lndmon with "+
+		"--lnd.macaroonname=%v to use it", macaroonPath,
+		b.MacaroonName)
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package collectors
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/routing/route"
+)
+
+// aliasCacheExpiry is the amount of time after which we'll look up a node's
+// alias again.
+const aliasCacheExpiry = 6 * time.Hour
+
+// aliasEntry is a cached alias along with the time at which it should be
+// looked up again.
+type aliasEntry struct {
+	alias  string
+	expiry time.Time
+}
+
+// aliasCache resolves node pubkeys to their announced aliases using
+// GetNodeInfo. Since we need a separate call for every node, aliases are looked
+// up in the background rather than during a scrape, and cached so that we
+// don't need to query lnd for every peer on every scrape. The cache is shared
+// by all collectors that export aliases.
+type aliasCache struct {
+	lnd lndclient.LightningClient
+
+	// aliases holds the aliases we've looked up, and pending the nodes
+	// whose aliases we still need to look up. Both are guarded by mutex.
+	aliases map[route.Vertex]aliasEntry
+	pending map[route.Vertex]struct{}
+	mutex   sync.Mutex
+
+	// lookupSignal is signaled whenever nodes were added to pending.
+	lookupSignal chan struct{}
+
+	// quit is closed to signal that we need to shutdown.
+	quit chan struct{}
+
+	wg sync.WaitGroup
+}
+
+// newAliasCache creates a new alias cache backed by the given lnd client.
+func newAliasCache(lnd lndclient.LightningClient) *aliasCache {
+	return &aliasCache{
+		lnd:          lnd,
+		aliases:      make(map[route.Vertex]aliasEntry),
+		pending:      make(map[route.Vertex]struct{}),
+		lookupSignal: make(chan struct{}, 1),
+		quit:         make(chan struct{}),
+	}
+}
+
+// start launches the goroutine that looks up the aliases we're asked for.
+func (a *aliasCache) start() {
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+
+		for {
+			select {
+			case <-a.lookupSignal:
+				a.lookupPending()
+
+			case <-a.quit:
+				return
+			}
+		}
+	}()
+}
+
+// stop sends the alias cache's goroutine the instruction to shutdown and waits
+// for it to exit.
+func (a *aliasCache) stop() {
+	close(a.quit)
+	a.wg.Wait()
+}
+
+// get returns the cached alias of the given node without blocking. If we
+// haven't looked up the node yet, or its alias expired, a lookup is scheduled
+// and the alias we have so far is returned, which is empty for nodes we didn't
+// look up before.
+func (a *aliasCache) get(pubkey route.Vertex) string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	entry, ok := a.aliases[pubkey]
+	if ok && time.Now().Before(entry.expiry) {
+		return entry.alias
+	}
+
+	if _, ok := a.pending[pubkey]; !ok {
+		a.pending[pubkey] = struct{}{}
+
+		select {
+		case a.lookupSignal <- struct{}{}:
+		default:
+		}
+	}
+
+	return entry.alias
+}
+
+// lookupPending looks up the aliases of all pending nodes, one at a time.
+func (a *aliasCache) lookupPending() {
+	for {
+		a.mutex.Lock()
+		var (
+			pubkey route.Vertex
+			found  bool
+		)
+		for pubkey = range a.pending {
+			found = true
+			break
+		}
+		a.mutex.Unlock()
+
+		if !found {
+			return
+		}
+
+		entry := a.lookup(pubkey)
+
+		a.mutex.Lock()
+		a.aliases[pubkey] = entry
+		delete(a.pending, pubkey)
+		a.mutex.Unlock()
+
+		select {
+		case <-a.quit:
+			return
+
+		default:
+		}
+	}
+}
+
+// lookup queries lnd for the alias of the given node. If the node is not known
+// to our graph (for example because all of its channels are private), its
+// alias is empty. Failed lookups expire sooner so that we retry them, but not
+// on every scrape.
+func (a *aliasCache) lookup(pubkey route.Vertex) aliasEntry {
+	entry := aliasEntry{
+		expiry: time.Now().Add(aliasCacheExpiry),
+	}
+
+	nodeInfo, err := a.lnd.GetNodeInfo(context.Background(), pubkey, false)
+	switch {
+	// We don't fail the scrape if we can't look up an alias, we just
+	// report it as empty.
+	case err != nil:
+		Logger.Debugf("Unable to look up alias for %v: %v", pubkey, err)
+		entry.expiry = time.Now().Add(cacheRefreshInterval)
+
+	case nodeInfo.Node != nil:
+		entry.alias = nodeInfo.Node.Alias
+	}
+
+	return entry
+}
@@ -0,0 +1,86 @@
+package collectors
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/stretchr/testify/require"
+)
+
+// mockAliasClient is a mock lightning client that knows the aliases of a fixed
+// set of nodes.
+type mockAliasClient struct {
+	lndclient.LightningClient
+
+	// aliases holds the aliases of the nodes in our graph.
+	aliases map[route.Vertex]string
+
+	// lookupErr is returned by all lookups if set.
+	lookupErr error
+
+	// lookups counts the lookups per node.
+	lookups map[route.Vertex]int
+}
+
+// GetNodeInfo returns the alias of the given node.
+func (m *mockAliasClient) GetNodeInfo(_ context.Context, pubkey route.Vertex,
+	_ bool) (*lndclient.NodeInfo, error) {
+
+	m.lookups[pubkey]++
+
+	if m.lookupErr != nil {
+		return nil, m.lookupErr
+	}
+
+	alias, ok := m.aliases[pubkey]
+	if !ok {
+		return &lndclient.NodeInfo{}, nil
+	}
+
+	return &lndclient.NodeInfo{
+		Node: &lndclient.Node{Alias: alias},
+	}, nil
+}
+
+// TestAliasCache tests that aliases are looked up in the background once and
+// that failed lookups keep the alias empty.
+func TestAliasCache(t *testing.T) {
+	var (
+		known   = route.Vertex{1}
+		unknown = route.Vertex{2}
+		client  = &mockAliasClient{
+			aliases: map[route.Vertex]string{known: "alice"},
+			lookups: make(map[route.Vertex]int),
+		}
+		cache = newAliasCache(client)
+	)
+
+	// Aliases aren't looked up while we get them, but only once the
+	// pending lookups are processed.
+	require.Empty(t, cache.get(known))
+	require.Empty(t, cache.get(known))
+	require.Empty(t, cache.get(unknown))
+	require.Empty(t, client.lookups)
+
+	cache.lookupPending()
+	require.Equal(t, "alice", cache.get(known))
+	require.Empty(t, cache.get(unknown))
+	require.Equal(t, map[route.Vertex]int{
+		known: 1, unknown: 1,
+	}, client.lookups)
+
+	// Cached aliases aren't looked up again.
+	cache.lookupPending()
+	require.Equal(t, 1, client.lookups[known])
+
+	// A failed lookup is reported as an empty alias.
+	client.lookupErr = errors.New("unavailable")
+	other := route.Vertex{3}
+	require.Empty(t, cache.get(other))
+	cache.lookupPending()
+	require.Empty(t, cache.get(other))
+	require.Equal(t, 1, client.lookups[other])
+}
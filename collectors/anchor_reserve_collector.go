@@ -0,0 +1,125 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnrpc/walletrpc"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// AnchorReserveCollector is a collector that checks whether our wallet holds
+// enough confirmed funds to bump the fees of our anchor channels' commitment
+// transactions.
+type AnchorReserveCollector struct {
+	lnd *lndclient.LndServices
+
+	requiredReserveDesc *prometheus.Desc
+	shortfallDesc       *prometheus.Desc
+	underReservedDesc   *prometheus.Desc
+
+	// errChan is a channel that we send any errors that we encounter into.
+	// This channel should be buffered so that it does not block sends.
+	errChan chan<- error
+}
+
+// NewAnchorReserveCollector returns a new instance of the
+// AnchorReserveCollector.
+func NewAnchorReserveCollector(lnd *lndclient.LndServices,
+	errChan chan<- error) *AnchorReserveCollector {
+
+	return &AnchorReserveCollector{
+		lnd: lnd,
+		requiredReserveDesc: prometheus.NewDesc(
+			"lnd_wallet_anchor_reserve_required_sat",
+			"confirmed wallet balance required to bump the fees "+
+				"of our anchor channels",
+			nil, nil,
+		),
+		shortfallDesc: prometheus.NewDesc(
+			"lnd_wallet_anchor_reserve_shortfall_sat",
+			"amount our confirmed wallet balance falls short of "+
+				"the required anchor reserve",
+			nil, nil,
+		),
+		underReservedDesc: prometheus.NewDesc(
+			"lnd_wallet_anchor_under_reserved",
+			"whether our confirmed wallet balance is below the "+
+				"required anchor reserve",
+			nil, nil,
+		),
+		errChan: errChan,
+	}
+}
+
+// anchorReserveShortfall returns the amount that our confirmed balance falls
+// short of the required reserve.
+func anchorReserveShortfall(required,
+	confirmed btcutil.Amount) btcutil.Amount {
+
+	if confirmed >= required {
+		return 0
+	}
+
+	return required - confirmed
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once the
+// last descriptor has been sent.
+//
+// NOTE: Part of the prometheus.Collector interface.
+func (a *AnchorReserveCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- a.requiredReserveDesc
+	ch <- a.shortfallDesc
+	ch <- a.underReservedDesc
+}
+
+// Collect is called by the Prometheus registry when collecting metrics.
+//
+// NOTE: Part of the prometheus.Collector interface.
+func (a *AnchorReserveCollector) Collect(ch chan<- prometheus.Metric) {
+	// We let lnd compute the reserve, since it knows which of our open and
+	// pending channels use anchors and how much it reserves for them.
+	rpcCtx, timeout, client := a.lnd.WalletKit.RawClientWithMacAuth(
+		context.Background(),
+	)
+	rpcCtx, cancel := context.WithTimeout(rpcCtx, timeout)
+	defer cancel()
+
+	resp, err := client.RequiredReserve(
+		rpcCtx, &walletrpc.RequiredReserveRequest{},
+	)
+	if err != nil {
+		a.errChan <- fmt.Errorf("AnchorReserveCollector "+
+			"RequiredReserve failed with: %v", err)
+		return
+	}
+	required := btcutil.Amount(resp.RequiredReserve)
+
+	walletBal, err := a.lnd.Client.WalletBalance(context.Background())
+	if err != nil {
+		a.errChan <- fmt.Errorf("AnchorReserveCollector WalletBalance "+
+			"failed with: %v", err)
+		return
+	}
+
+	shortfall := anchorReserveShortfall(required, walletBal.Confirmed)
+
+	var underReserved float64
+	if shortfall > 0 {
+		underReserved = 1
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		a.requiredReserveDesc, prometheus.GaugeValue, float64(required),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		a.shortfallDesc, prometheus.GaugeValue, float64(shortfall),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		a.underReservedDesc, prometheus.GaugeValue, underReserved,
+	)
+}
@@ -0,0 +1,171 @@
+package collectors
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnrpc/walletrpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// mockWalletKit is a mock wallet kit client that hands out a mock raw client.
+type mockWalletKit struct {
+	lndclient.WalletKitClient
+
+	// raw is the raw wallet kit client.
+	raw walletrpc.WalletKitClient
+}
+
+// RawClientWithMacAuth returns the mock raw wallet kit client.
+func (m *mockWalletKit) RawClientWithMacAuth(
+	ctx context.Context) (context.Context, time.Duration,
+	walletrpc.WalletKitClient) {
+
+	return ctx, time.Second, m.raw
+}
+
+// mockReserveClient is a mock raw wallet kit client that reports a fixed
+// required anchor reserve.
+type mockReserveClient struct {
+	walletrpc.WalletKitClient
+
+	// required is the anchor reserve that lnd requires.
+	required btcutil.Amount
+
+	// reserveErr is returned by RequiredReserve if set.
+	reserveErr error
+}
+
+// RequiredReserve returns the required anchor reserve.
+func (m *mockReserveClient) RequiredReserve(context.Context,
+	*walletrpc.RequiredReserveRequest, ...grpc.CallOption) (
+	*walletrpc.RequiredReserveResponse, error) {
+
+	if m.reserveErr != nil {
+		return nil, m.reserveErr
+	}
+
+	return &walletrpc.RequiredReserveResponse{
+		RequiredReserve: int64(m.required),
+	}, nil
+}
+
+// mockBalanceClient is a mock lightning client that reports a fixed wallet
+// balance.
+type mockBalanceClient struct {
+	lndclient.LightningClient
+
+	// confirmed is our confirmed wallet balance.
+	confirmed btcutil.Amount
+
+	// balanceErr is returned by WalletBalance if set.
+	balanceErr error
+}
+
+// WalletBalance returns our wallet balance.
+func (m *mockBalanceClient) WalletBalance(
+	context.Context) (*lndclient.WalletBalance, error) {
+
+	if m.balanceErr != nil {
+		return nil, m.balanceErr
+	}
+
+	return &lndclient.WalletBalance{Confirmed: m.confirmed}, nil
+}
+
+// TestAnchorReserveShortfall tests that we only report a shortfall if our
+// confirmed balance is below the required anchor reserve.
+func TestAnchorReserveShortfall(t *testing.T) {
+	require.Zero(t, anchorReserveShortfall(0, 0))
+	require.Zero(t, anchorReserveShortfall(10_000, 10_000))
+	require.Zero(t, anchorReserveShortfall(10_000, 50_000))
+	require.EqualValues(t, 7_000, anchorReserveShortfall(10_000, 3_000))
+}
+
+// TestAnchorReserveCollector tests that we export the anchor reserve and
+// whether our wallet holds it, and that failing calls are reported as errors
+// without exporting any metrics.
+func TestAnchorReserveCollector(t *testing.T) {
+	tests := []struct {
+		name          string
+		reserveErr    error
+		balanceErr    error
+		confirmed     btcutil.Amount
+		shortfall     float64
+		underReserved float64
+	}{
+		{
+			name:      "reserve met",
+			confirmed: 50_000,
+		},
+		{
+			name:          "under reserved",
+			confirmed:     3_000,
+			shortfall:     7_000,
+			underReserved: 1,
+		},
+		{
+			name:       "required reserve error",
+			reserveErr: errors.New("unavailable"),
+		},
+		{
+			name:       "wallet balance error",
+			balanceErr: errors.New("unavailable"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			walletKit := &mockWalletKit{
+				raw: &mockReserveClient{
+					required:   10_000,
+					reserveErr: test.reserveErr,
+				},
+			}
+			client := &mockBalanceClient{
+				confirmed:  test.confirmed,
+				balanceErr: test.balanceErr,
+			}
+
+			errChan := make(chan error, 1)
+			collector := NewAnchorReserveCollector(
+				&lndclient.LndServices{
+					Client:    client,
+					WalletKit: walletKit,
+				}, errChan,
+			)
+
+			ch := make(chan prometheus.Metric, 3)
+			collector.Collect(ch)
+			close(ch)
+
+			series := collectMetrics(t, ch)
+
+			if test.reserveErr != nil || test.balanceErr != nil {
+				require.Error(t, <-errChan)
+				require.Empty(t, series)
+
+				return
+			}
+
+			require.Empty(t, errChan)
+			value := func(desc *prometheus.Desc) float64 {
+				require.Len(t, series[desc], 1)
+				return series[desc][0].value
+			}
+
+			require.Equal(t, 10_000.0,
+				value(collector.requiredReserveDesc))
+			require.Equal(t, test.shortfall,
+				value(collector.shortfallDesc))
+			require.Equal(t, test.underReserved,
+				value(collector.underReservedDesc))
+		})
+	}
+}
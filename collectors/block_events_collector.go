@@ -0,0 +1,387 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnrpc/chainrpc"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// BlocksConfig is the set of configuration data that specifies how the blocks
+// lnd is notified of are monitored.
+type BlocksConfig struct {
+	// Disable disables the collection of block metrics.
+	Disable bool `long:"disable" description:"Do not collect block notification metrics"`
+
+	// StaleTimeout is the time without a new block after which we
+	// consider lnd's view of the chain stale.
+	StaleTimeout time.Duration `long:"staletimeout" description:"The time without a new block after which lnd's view of the chain is reported as stale. Valid time units are {s, m, h}."`
+}
+
+// DefaultBlocksConfig returns the default blocks configuration.
+func DefaultBlocksConfig() *BlocksConfig {
+	return &BlocksConfig{
+		StaleTimeout: time.Hour,
+	}
+}
+
+// Validate checks that the blocks configuration is sane.
+func (c *BlocksConfig) Validate() error {
+	if c.Disable {
+		return nil
+	}
+
+	if c.StaleTimeout <= 0 {
+		return fmt.Errorf("blocks stale timeout must be positive, "+
+			"got %v", c.StaleTimeout)
+	}
+
+	return nil
+}
+
+// blockEventsMonitor subscribes to the blocks lnd's chain notifier sees, to
+// track how regularly blocks arrive, how long lnd takes to notice them and
+// whether the chain reorganizes.
+type blockEventsMonitor struct {
+	lnd *lndclient.LndServices
+
+	cfg *BlocksConfig
+
+	// The fields below track the tip of the chain. They are guarded by
+	// tipMtx.
+	tipMtx sync.Mutex
+
+	// haveTip is false until we were notified of our first block.
+	haveTip bool
+
+	// subscribed is true until we were notified of the first block since
+	// we (re)subscribed. That block is the tip of the chain at the time we
+	// subscribed rather than a new block, so we don't time it.
+	subscribed bool
+
+	// tipHeight and tipHash identify the last block we were notified of.
+	tipHeight uint32
+	tipHash   chainhash.Hash
+
+	// lastBlock is the time at which we were last notified of a new
+	// block, or the time we started monitoring before that.
+	lastBlock time.Time
+
+	reorgCounter prometheus.Counter
+
+	blockInterval     prometheus.Histogram
+	notificationDelay prometheus.Histogram
+
+	lastBlockDesc *prometheus.Desc
+	staleDesc     *prometheus.Desc
+
+	// quit is closed to signal that we need to shutdown.
+	quit chan struct{}
+
+	wg sync.WaitGroup
+
+	// errChan is a channel that we send any errors that we encounter into.
+	// This channel should be buffered so that it does not block sends.
+	errChan chan<- error
+}
+
+// A compile time check to ensure that blockEventsMonitor implements the
+// prometheus.Collector interface.
+var _ prometheus.Collector = (*blockEventsMonitor)(nil)
+
+// newBlockEventsMonitor creates a new block events monitor.
+func newBlockEventsMonitor(lnd *lndclient.LndServices, cfg *BlocksConfig,
+	errChan chan error) *blockEventsMonitor {
+
+	return &blockEventsMonitor{
+		lnd: lnd,
+		cfg: cfg,
+		reorgCounter: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "lnd",
+			Subsystem: "block",
+			Name:      "reorgs_total",
+			Help: "number of chain reorganizations lnd was " +
+				"notified of",
+		}),
+		blockInterval: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Namespace: "lnd",
+				Subsystem: "block",
+				Name:      "interval_seconds",
+				Help: "time between lnd notifying us of " +
+					"consecutive blocks",
+				Buckets: prometheus.ExponentialBuckets(
+					15, 2, 10,
+				),
+			},
+		),
+		notificationDelay: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Namespace: "lnd",
+				Subsystem: "block",
+				Name:      "notification_delay_seconds",
+				Help: "time from a block's header timestamp " +
+					"until lnd notified us of it",
+				Buckets: prometheus.ExponentialBuckets(
+					1, 2, 12,
+				),
+			},
+		),
+		lastBlockDesc: prometheus.NewDesc(
+			"lnd_block_last_notification_age_seconds",
+			"time since lnd last notified us of a new block",
+			nil, nil,
+		),
+		staleDesc: prometheus.NewDesc(
+			"lnd_chain_stale",
+			"whether lnd hasn't notified us of a new block within "+
+				"the stale timeout",
+			nil, nil,
+		),
+		quit:    make(chan struct{}),
+		errChan: errChan,
+	}
+}
+
+// start subscribes to block notifications and begins the main event loop of
+// the monitor.
+func (b *blockEventsMonitor) start() error {
+	Logger.Info("Starting block events monitor")
+
+	// We're restarted whenever lnd was unavailable, so we need a fresh
+	// quit channel each time.
+	b.quit = make(chan struct{})
+	b.resubscribe(time.Now())
+
+	// Create a context to subscribe to blocks and cancel it on exit so
+	// that lnd can cancel the stream. We use the raw client since
+	// lndclient only delivers the heights of blocks, and we need their
+	// hashes to detect reorgs.
+	ctx, cancel := context.WithCancel(context.Background())
+
+	rpcCtx, _, client := b.lnd.ChainNotifier.RawClientWithMacAuth(ctx)
+	stream, err := client.RegisterBlockEpochNtfn(
+		rpcCtx, &chainrpc.BlockEpoch{},
+	)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	// The stream can only be read with blocking calls, so we read it in a
+	// separate goroutine and deliver its blocks to our main loop.
+	blocks := make(chan *chainrpc.BlockEpoch)
+	streamErr := make(chan error, 1)
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		for {
+			block, err := stream.Recv()
+			if err != nil {
+				streamErr <- err
+				return
+			}
+
+			select {
+			case blocks <- block:
+			case <-b.quit:
+				return
+			}
+		}
+	}()
+
+	b.wg.Add(1)
+	go func() {
+		defer func() {
+			cancel()
+			b.wg.Done()
+		}()
+
+		for {
+			select {
+			case block := <-blocks:
+				err := b.handleBlock(ctx, block)
+				if err != nil {
+					sendError(b.errChan, b.quit, err)
+					return
+				}
+
+			case err := <-streamErr:
+				sendError(b.errChan, b.quit, fmt.Errorf(
+					"block epoch stream exited: %v", err,
+				))
+				return
+
+			case <-b.quit:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// stop sends the block events monitor's goroutines the instruction to
+// shutdown and waits for them to exit.
+func (b *blockEventsMonitor) stop() {
+	Logger.Info("Stopping block events monitor")
+
+	close(b.quit)
+	b.wg.Wait()
+}
+
+// collectors returns all of the collectors that the block events monitor
+// uses. Since the age of the last block depends on the time of the scrape,
+// the monitor itself collects the staleness metrics.
+func (b *blockEventsMonitor) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		b.reorgCounter, b.blockInterval, b.notificationDelay, b,
+	}
+}
+
+// resubscribe prepares the monitor for a new block subscription at the given
+// time. We keep our tip across restarts, so that we still detect reorgs that
+// happened in the meantime.
+func (b *blockEventsMonitor) resubscribe(now time.Time) {
+	b.tipMtx.Lock()
+	defer b.tipMtx.Unlock()
+
+	if b.lastBlock.IsZero() {
+		b.lastBlock = now
+	}
+	b.subscribed = true
+}
+
+// handleBlock fetches the header of a block we were notified of and records
+// it.
+func (b *blockEventsMonitor) handleBlock(ctx context.Context,
+	block *chainrpc.BlockEpoch) error {
+
+	now := time.Now()
+
+	hash, err := chainhash.NewHash(block.Hash)
+	if err != nil {
+		return fmt.Errorf("invalid block epoch hash: %v", err)
+	}
+
+	header, err := b.lnd.ChainKit.GetBlockHeader(ctx, *hash)
+	if err != nil {
+		return fmt.Errorf("block events monitor GetBlockHeader "+
+			"failed with: %v", err)
+	}
+
+	b.processBlock(block.Height, *hash, header, now)
+
+	return nil
+}
+
+// processBlock records lnd notifying us of the given block at the given time.
+func (b *blockEventsMonitor) processBlock(height uint32, hash chainhash.Hash,
+	header *wire.BlockHeader, now time.Time) {
+
+	b.tipMtx.Lock()
+	defer b.tipMtx.Unlock()
+
+	// The first block we're notified of is the tip of the chain at the
+	// time we subscribed, which may have been found long before.
+	if !b.haveTip {
+		b.haveTip = true
+		b.subscribed = false
+		b.tipHeight = height
+		b.tipHash = hash
+		b.lastBlock = now
+
+		return
+	}
+
+	// After a restart, the first block we're notified of is the current
+	// tip as well. We still compare it with our previous tip to detect
+	// reorgs that happened in the meantime, but we don't time it.
+	initial := b.subscribed
+	b.subscribed = false
+
+	// lnd may notify us of the same block more than once.
+	if height == b.tipHeight && hash == b.tipHash {
+		return
+	}
+
+	// A block that doesn't extend our tip means that the chain was
+	// reorganized. If lnd skipped blocks, we can't tell whether the new
+	// block builds on our tip.
+	switch {
+	case height <= b.tipHeight:
+		Logger.Infof("Chain reorganized from block %v at height %d "+
+			"to block %v at height %d", b.tipHash, b.tipHeight,
+			hash, height)
+		b.reorgCounter.Inc()
+
+	case height == b.tipHeight+1 && header.PrevBlock != b.tipHash:
+		Logger.Infof("Chain reorganized, block %v at height %d "+
+			"doesn't build on block %v", hash, height, b.tipHash)
+		b.reorgCounter.Inc()
+
+	case height == b.tipHeight+1 && !initial:
+		b.blockInterval.Observe(now.Sub(b.lastBlock).Seconds())
+	}
+
+	// Block timestamps are only loosely bound to the actual time, so they
+	// may lie in the future.
+	delay := now.Sub(header.Timestamp)
+	if delay < 0 {
+		delay = 0
+	}
+	if !initial {
+		b.notificationDelay.Observe(delay.Seconds())
+	}
+
+	b.tipHeight = height
+	b.tipHash = hash
+	b.lastBlock = now
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once the
+// last descriptor has been sent.
+//
+// NOTE: Part of the prometheus.Collector interface.
+func (b *blockEventsMonitor) Describe(ch chan<- *prometheus.Desc) {
+	ch <- b.lastBlockDesc
+	ch <- b.staleDesc
+}
+
+// Collect is called by the Prometheus registry when collecting metrics.
+//
+// NOTE: Part of the prometheus.Collector interface.
+func (b *blockEventsMonitor) Collect(ch chan<- prometheus.Metric) {
+	b.collectStaleness(ch, time.Now())
+}
+
+// collectStaleness exports how long ago we were last notified of a new block
+// at the given time, and whether that makes lnd's view of the chain stale.
+func (b *blockEventsMonitor) collectStaleness(ch chan<- prometheus.Metric,
+	now time.Time) {
+
+	b.tipMtx.Lock()
+	age := now.Sub(b.lastBlock)
+	b.tipMtx.Unlock()
+
+	var stale float64
+	if age > b.cfg.StaleTimeout {
+		stale = 1
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		b.lastBlockDesc, prometheus.GaugeValue, age.Seconds(),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		b.staleDesc, prometheus.GaugeValue, stale,
+	)
+}
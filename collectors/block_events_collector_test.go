@@ -0,0 +1,117 @@
+package collectors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBlockEventsMonitor tests that we time the blocks we're notified of,
+// detect reorgs and report when the chain goes stale.
+func TestBlockEventsMonitor(t *testing.T) {
+	var (
+		monitor = newBlockEventsMonitor(
+			nil, DefaultBlocksConfig(), make(chan error, 1),
+		)
+		start = time.Unix(1_000_000, 0)
+	)
+
+	hash := func(i byte) chainhash.Hash {
+		return chainhash.Hash{i}
+	}
+
+	block := func(height uint32, h, prev byte, offset,
+		delay time.Duration) {
+
+		header := &wire.BlockHeader{
+			PrevBlock: hash(prev),
+			Timestamp: start.Add(offset - delay),
+		}
+		monitor.processBlock(height, hash(h), header, start.Add(offset))
+	}
+
+	// The tip we're notified of when subscribing isn't timed, since it
+	// may have been found long before we started.
+	monitor.resubscribe(start)
+	block(100, 1, 0, 0, time.Hour)
+	require.Zero(t, histogramCount(t, monitor.blockInterval))
+	require.Zero(t, histogramCount(t, monitor.notificationDelay))
+
+	// A block extending our tip is timed, a repeated notification isn't.
+	block(101, 2, 1, 10*time.Minute, 5*time.Second)
+	block(101, 2, 1, 11*time.Minute, 5*time.Second)
+	require.EqualValues(t, 1, histogramCount(t, monitor.blockInterval))
+	require.EqualValues(t, 1, histogramCount(t, monitor.notificationDelay))
+	require.Zero(t, testutil.ToFloat64(monitor.reorgCounter))
+
+	// A block replacing our tip at the same height is a reorg, as is a
+	// block on top of it that doesn't build on our tip.
+	block(101, 3, 1, 12*time.Minute, 0)
+	require.Equal(t, 1.0, testutil.ToFloat64(monitor.reorgCounter))
+
+	block(102, 4, 2, 13*time.Minute, 0)
+	require.Equal(t, 2.0, testutil.ToFloat64(monitor.reorgCounter))
+
+	// A block that builds on our tip isn't.
+	block(103, 5, 4, 20*time.Minute, 0)
+	require.Equal(t, 2.0, testutil.ToFloat64(monitor.reorgCounter))
+	require.EqualValues(t, 2, histogramCount(t, monitor.blockInterval))
+
+	// The chain only goes stale once we haven't seen a new block within
+	// the stale timeout.
+	staleness := func(offset time.Duration) (float64, float64) {
+		ch := make(chan prometheus.Metric, 2)
+		monitor.collectStaleness(ch, start.Add(offset))
+
+		age, stale := &dto.Metric{}, &dto.Metric{}
+		require.NoError(t, (<-ch).Write(age))
+		require.NoError(t, (<-ch).Write(stale))
+
+		return age.GetGauge().GetValue(), stale.GetGauge().GetValue()
+	}
+
+	age, stale := staleness(50 * time.Minute)
+	require.Equal(t, (30 * time.Minute).Seconds(), age)
+	require.Zero(t, stale)
+
+	age, stale = staleness(2 * time.Hour)
+	require.Equal(t, (100 * time.Minute).Seconds(), age)
+	require.Equal(t, 1.0, stale)
+
+	// After a restart, the tip we're notified of isn't timed either, even
+	// if it extends our previous tip.
+	monitor.resubscribe(start.Add(3 * time.Hour))
+	block(104, 6, 5, 3*time.Hour, 2*time.Hour)
+	require.EqualValues(t, 2, histogramCount(t, monitor.blockInterval))
+	require.EqualValues(t, 4, histogramCount(t, monitor.notificationDelay))
+	require.Equal(t, 2.0, testutil.ToFloat64(monitor.reorgCounter))
+
+	// The next block is timed again.
+	block(105, 7, 6, 3*time.Hour+5*time.Minute, 0)
+	require.EqualValues(t, 3, histogramCount(t, monitor.blockInterval))
+	require.EqualValues(t, 5, histogramCount(t, monitor.notificationDelay))
+
+	// A tip that doesn't build on our previous tip after a restart is
+	// still detected as a reorg.
+	monitor.resubscribe(start.Add(4 * time.Hour))
+	block(106, 8, 1, 4*time.Hour, 0)
+	require.Equal(t, 3.0, testutil.ToFloat64(monitor.reorgCounter))
+	require.EqualValues(t, 3, histogramCount(t, monitor.blockInterval))
+	require.EqualValues(t, 5, histogramCount(t, monitor.notificationDelay))
+}
+
+// histogramCount returns the number of observations of the given histogram.
+func histogramCount(t *testing.T, h prometheus.Histogram) uint64 {
+	t.Helper()
+
+	metric := &dto.Metric{}
+	require.NoError(t, h.Write(metric))
+
+	return metric.GetHistogram().GetSampleCount()
+}
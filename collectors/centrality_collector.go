@@ -0,0 +1,489 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// reachabilityHops is the set of hop counts we report the number of reachable
+// nodes for.
+var reachabilityHops = []int{1, 2, 3}
+
+// CentralityConfig is the set of configuration data that specifies how the
+// centrality and reachability metrics of our node are computed.
+type CentralityConfig struct {
+	// Disable disables the computation of centrality and reachability
+	// metrics.
+	Disable bool `long:"disable" description:"Do not compute centrality and reachability metrics of our node"`
+
+	// Interval is the interval at which the metrics are recomputed.
+	Interval time.Duration `long:"interval" description:"The interval at which centrality and reachability metrics are recomputed. Valid time units are {s, m, h}."`
+
+	// MaxSamples is the maximum number of source nodes that are sampled
+	// to approximate betweenness centrality.
+	MaxSamples int `long:"maxsamples" description:"Maximum number of source nodes to sample when approximating betweenness centrality (0 for an exact computation)"`
+}
+
+// DefaultCentralityConfig returns the default centrality configuration.
+func DefaultCentralityConfig() *CentralityConfig {
+	return &CentralityConfig{
+		Interval:   time.Hour,
+		MaxSamples: 1000,
+	}
+}
+
+// Validate checks that the centrality configuration is sane.
+func (c *CentralityConfig) Validate() error {
+	if c.Disable {
+		return nil
+	}
+
+	if c.Interval <= 0 {
+		return fmt.Errorf("centrality interval must be positive, "+
+			"got %v", c.Interval)
+	}
+
+	if c.MaxSamples < 0 {
+		return fmt.Errorf("centrality max samples must not be "+
+			"negative, got %d", c.MaxSamples)
+	}
+
+	return nil
+}
+
+// centralityMonitor periodically computes the position of our node in the
+// channel graph. Since this is too expensive to do on every scrape, the
+// metrics are computed in the background and exported using the built-in
+// prometheus types.
+type centralityMonitor struct {
+	cfg *CentralityConfig
+
+	// graph is the source we fetch the channel graph from.
+	graph graphSource
+
+	// self is our own node's pubkey.
+	self route.Vertex
+
+	betweennessGauge     prometheus.Gauge
+	betweennessRankGauge prometheus.Gauge
+	reachableNodesGauge  *prometheus.GaugeVec
+	avgHopDistanceGauge  prometheus.Gauge
+	reachableCapRatio    prometheus.Gauge
+	computeDurationGauge prometheus.Gauge
+
+	// quit is closed to signal that we need to shutdown.
+	quit chan struct{}
+
+	wg sync.WaitGroup
+
+	// errChan is a channel that we send any errors that we encounter into.
+	// This channel should be buffered so that it does not block sends.
+	errChan chan<- error
+}
+
+// newCentralityMonitor creates a new centrality monitor.
+func newCentralityMonitor(cfg *CentralityConfig, graph graphSource,
+	self route.Vertex, errChan chan error) *centralityMonitor {
+
+	newGauge := func(name, help string) prometheus.Gauge {
+		return prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "lnd",
+			Subsystem: "graph_node",
+			Name:      name,
+			Help:      help,
+		})
+	}
+
+	return &centralityMonitor{
+		cfg:   cfg,
+		graph: graph,
+		self:  self,
+		betweennessGauge: newGauge(
+			"betweenness_centrality",
+			"normalized betweenness centrality of our node",
+		),
+		betweennessRankGauge: newGauge(
+			"betweenness_rank",
+			"rank of our node by betweenness centrality, 1 being "+
+				"the most central node",
+		),
+		reachableNodesGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: "lnd",
+				Subsystem: "graph_node",
+				Name:      "reachable_nodes",
+				Help: "number of nodes reachable from our " +
+					"node within a number of hops",
+			}, []string{"hops"},
+		),
+		avgHopDistanceGauge: newGauge(
+			"avg_hop_distance",
+			"avg number of hops from our node to all reachable "+
+				"nodes",
+		),
+		reachableCapRatio: newGauge(
+			"reachable_capacity_ratio",
+			"fraction of the network capacity that is reachable "+
+				"from our node",
+		),
+		computeDurationGauge: newGauge(
+			"centrality_computation_seconds",
+			"time taken to compute the centrality metrics",
+		),
+		quit:    make(chan struct{}),
+		errChan: errChan,
+	}
+}
+
+// start launches the goroutine that periodically recomputes our metrics.
+func (c *centralityMonitor) start() {
+	Logger.Info("Starting centrality monitor")
+
+	// We're restarted whenever lnd was unavailable, so we need a fresh
+	// quit channel each time.
+	c.quit = make(chan struct{})
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		ticker := time.NewTicker(c.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			if err := c.update(); err != nil {
+				sendError(c.errChan, c.quit, err)
+				return
+			}
+
+			select {
+			case <-ticker.C:
+				continue
+
+			case <-c.quit:
+				return
+			}
+		}
+	}()
+}
+
+// stop sends the centrality monitor's goroutines the instruction to shutdown
+// and waits for them to exit.
+func (c *centralityMonitor) stop() {
+	Logger.Info("Stopping centrality monitor")
+
+	close(c.quit)
+	c.wg.Wait()
+}
+
+// collectors returns all of the collectors that the centrality monitor uses.
+func (c *centralityMonitor) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.betweennessGauge, c.betweennessRankGauge,
+		c.reachableNodesGauge, c.avgHopDistanceGauge,
+		c.reachableCapRatio, c.computeDurationGauge,
+	}
+}
+
+// update fetches the current graph and recomputes all of our metrics.
+func (c *centralityMonitor) update() error {
+	graph, err := c.graph.DescribeGraph(context.Background(), false)
+	if err != nil {
+		return fmt.Errorf("centrality monitor DescribeGraph failed "+
+			"with: %v", err)
+	}
+
+	start := time.Now()
+
+	g := newAdjacencyGraph(graph.Edges)
+	self, ok := g.index[c.self]
+	if !ok {
+		// Our node isn't part of the public graph (yet), so there's
+		// nothing to compute.
+		Logger.Debugf("Own node %v not found in graph, skipping "+
+			"centrality metrics", c.self)
+
+		return nil
+	}
+
+	centrality := g.betweenness(c.cfg.MaxSamples)
+	c.betweennessGauge.Set(centrality[self])
+	c.betweennessRankGauge.Set(float64(rank(centrality, self)))
+
+	reach := g.reachability(self)
+	for _, hops := range reachabilityHops {
+		c.reachableNodesGauge.WithLabelValues(
+			strconv.Itoa(hops),
+		).Set(float64(reach.withinHops(hops)))
+	}
+	c.avgHopDistanceGauge.Set(reach.avgDistance)
+	c.reachableCapRatio.Set(reach.capacityRatio)
+
+	elapsed := time.Since(start)
+	c.computeDurationGauge.Set(elapsed.Seconds())
+
+	Logger.Debugf("Computed centrality metrics for %d nodes in %v",
+		len(g.adjacency), elapsed)
+
+	return nil
+}
+
+// adjacencyGraph is an undirected representation of the channel graph that
+// is suitable for traversal.
+type adjacencyGraph struct {
+	// index maps each node to its position in the adjacency list.
+	index map[route.Vertex]int
+
+	// adjacency holds the neighbors of each node. Parallel channels
+	// between two nodes are collapsed into a single edge.
+	adjacency [][]int
+
+	// edges holds the usable channels of the graph and their capacity.
+	edges []adjacencyEdge
+
+	// totalCapacity is the capacity of all channels in the graph,
+	// including the ones that are not usable.
+	totalCapacity btcutil.Amount
+}
+
+// adjacencyEdge is a usable channel between two nodes.
+type adjacencyEdge struct {
+	node1, node2 int
+	capacity     btcutil.Amount
+}
+
+// newAdjacencyGraph builds an adjacency graph from a set of channel edges.
+// Channels are only considered usable if at least one of their directions has
+// an enabled routing policy.
+func newAdjacencyGraph(edges []lndclient.ChannelEdge) *adjacencyGraph {
+	g := &adjacencyGraph{
+		index: make(map[route.Vertex]int),
+	}
+
+	nodeIndex := func(node route.Vertex) int {
+		idx, ok := g.index[node]
+		if !ok {
+			idx = len(g.adjacency)
+			g.index[node] = idx
+			g.adjacency = append(g.adjacency, nil)
+		}
+
+		return idx
+	}
+
+	enabled := func(policy *lndclient.RoutingPolicy) bool {
+		return policy != nil && !policy.Disabled
+	}
+
+	seen := make(map[[2]int]struct{})
+	for _, edge := range edges {
+		g.totalCapacity += edge.Capacity
+
+		if !enabled(edge.Node1Policy) && !enabled(edge.Node2Policy) {
+			continue
+		}
+
+		node1, node2 := nodeIndex(edge.Node1), nodeIndex(edge.Node2)
+		g.edges = append(g.edges, adjacencyEdge{
+			node1:    node1,
+			node2:    node2,
+			capacity: edge.Capacity,
+		})
+
+		key := [2]int{node1, node2}
+		if _, ok := seen[key]; ok || node1 == node2 {
+			continue
+		}
+		seen[key] = struct{}{}
+
+		g.adjacency[node1] = append(g.adjacency[node1], node2)
+		g.adjacency[node2] = append(g.adjacency[node2], node1)
+	}
+
+	return g
+}
+
+// betweenness computes the normalized betweenness centrality of all nodes
+// using Brandes' algorithm. If maxSamples is non-zero and smaller than the
+// number of nodes, the centrality is approximated using a random sample of
+// source nodes.
+func (g *adjacencyGraph) betweenness(maxSamples int) []float64 {
+	n := len(g.adjacency)
+	centrality := make([]float64, n)
+	if n < 3 {
+		return centrality
+	}
+
+	sources := rand.Perm(n)
+	if maxSamples > 0 && maxSamples < n {
+		sources = sources[:maxSamples]
+	}
+
+	var (
+		stack = make([]int, 0, n)
+		queue = make([]int, 0, n)
+		preds = make([][]int, n)
+		sigma = make([]float64, n)
+		dist  = make([]int, n)
+		delta = make([]float64, n)
+	)
+	for _, s := range sources {
+		stack = stack[:0]
+		queue = queue[:0]
+		for i := range preds {
+			preds[i] = preds[i][:0]
+			sigma[i] = 0
+			dist[i] = -1
+			delta[i] = 0
+		}
+
+		sigma[s] = 1
+		dist[s] = 0
+		queue = append(queue, s)
+
+		// Count the shortest paths from the source to all other nodes
+		// using a breadth first search.
+		for len(queue) > 0 {
+			v := queue[0]
+			queue = queue[1:]
+			stack = append(stack, v)
+
+			for _, w := range g.adjacency[v] {
+				if dist[w] < 0 {
+					dist[w] = dist[v] + 1
+					queue = append(queue, w)
+				}
+				if dist[w] == dist[v]+1 {
+					sigma[w] += sigma[v]
+					preds[w] = append(preds[w], v)
+				}
+			}
+		}
+
+		// Accumulate the dependencies of the source on each node in
+		// order of non-increasing distance.
+		for i := len(stack) - 1; i >= 0; i-- {
+			w := stack[i]
+			for _, v := range preds[w] {
+				delta[v] += sigma[v] / sigma[w] * (1 + delta[w])
+			}
+			if w != s {
+				centrality[w] += delta[w]
+			}
+		}
+	}
+
+	// Scale the result to account for sampling and normalize it by the
+	// number of node pairs. Since the graph is undirected, every pair is
+	// counted twice if all nodes are used as sources.
+	scale := float64(n) / float64(len(sources)) /
+		float64((n-1)*(n-2))
+	for i := range centrality {
+		centrality[i] *= scale
+	}
+
+	return centrality
+}
+
+// rank returns the rank of the node at the given index, 1 being the highest
+// value.
+func rank(values []float64, idx int) int {
+	r := 1
+	for _, value := range values {
+		if value > values[idx] {
+			r++
+		}
+	}
+
+	return r
+}
+
+// reachabilityReport describes which part of the graph is reachable from a
+// node.
+type reachabilityReport struct {
+	// hopCounts holds the number of nodes at each hop distance, where the
+	// index is the number of hops.
+	hopCounts []int
+
+	// avgDistance is the avg number of hops to all reachable nodes.
+	avgDistance float64
+
+	// capacityRatio is the fraction of the total network capacity that is
+	// reachable.
+	capacityRatio float64
+}
+
+// withinHops returns the number of nodes that are reachable within the given
+// number of hops.
+func (r *reachabilityReport) withinHops(hops int) int {
+	var count int
+	for i := 1; i <= hops && i < len(r.hopCounts); i++ {
+		count += r.hopCounts[i]
+	}
+
+	return count
+}
+
+// reachability computes which part of the graph is reachable from the node
+// at the given index.
+func (g *adjacencyGraph) reachability(source int) *reachabilityReport {
+	dist := make([]int, len(g.adjacency))
+	for i := range dist {
+		dist[i] = -1
+	}
+	dist[source] = 0
+
+	report := &reachabilityReport{
+		hopCounts: []int{1},
+	}
+
+	var totalDist, numReachable int
+	queue := []int{source}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+
+		for _, w := range g.adjacency[v] {
+			if dist[w] >= 0 {
+				continue
+			}
+
+			dist[w] = dist[v] + 1
+			queue = append(queue, w)
+
+			if dist[w] >= len(report.hopCounts) {
+				report.hopCounts = append(report.hopCounts, 0)
+			}
+			report.hopCounts[dist[w]]++
+
+			totalDist += dist[w]
+			numReachable++
+		}
+	}
+
+	if numReachable > 0 {
+		report.avgDistance = float64(totalDist) / float64(numReachable)
+	}
+
+	var reachableCapacity btcutil.Amount
+	for _, edge := range g.edges {
+		if dist[edge.node1] >= 0 && dist[edge.node2] >= 0 {
+			reachableCapacity += edge.capacity
+		}
+	}
+	if g.totalCapacity > 0 {
+		report.capacityRatio = float64(reachableCapacity) /
+			float64(g.totalCapacity)
+	}
+
+	return report
+}
@@ -0,0 +1,75 @@
+package collectors
+
+import (
+	"testing"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAdjacencyGraphMetrics tests the centrality and reachability metrics on
+// a small graph.
+func TestAdjacencyGraphMetrics(t *testing.T) {
+	var (
+		nodeA = route.Vertex{1}
+		nodeB = route.Vertex{2}
+		nodeC = route.Vertex{3}
+		nodeD = route.Vertex{4}
+		nodeE = route.Vertex{5}
+		nodeF = route.Vertex{6}
+
+		enabled  = &lndclient.RoutingPolicy{}
+		disabled = &lndclient.RoutingPolicy{Disabled: true}
+	)
+
+	edge := func(node1, node2 route.Vertex,
+		policy *lndclient.RoutingPolicy) lndclient.ChannelEdge {
+
+		return lndclient.ChannelEdge{
+			Capacity:    100,
+			Node1:       node1,
+			Node2:       node2,
+			Node1Policy: policy,
+		}
+	}
+
+	// We create the path A-B-C-D, with a parallel channel between A and B
+	// that must not affect the number of shortest paths. The channel
+	// between E and F is disabled, so neither node is part of the graph.
+	g := newAdjacencyGraph([]lndclient.ChannelEdge{
+		edge(nodeA, nodeB, enabled),
+		edge(nodeA, nodeB, enabled),
+		edge(nodeB, nodeC, enabled),
+		edge(nodeC, nodeD, enabled),
+		edge(nodeE, nodeF, disabled),
+	})
+	require.Len(t, g.adjacency, 4)
+	require.NotContains(t, g.index, nodeE)
+
+	// On a path of four nodes, each inner node lies on the shortest paths
+	// of two out of three pairs of other nodes.
+	centrality := g.betweenness(0)
+	a, b, c, d := g.index[nodeA], g.index[nodeB], g.index[nodeC],
+		g.index[nodeD]
+	require.InDelta(t, 0.0, centrality[a], 1e-9)
+	require.InDelta(t, 2.0/3.0, centrality[b], 1e-9)
+	require.InDelta(t, 2.0/3.0, centrality[c], 1e-9)
+	require.InDelta(t, 0.0, centrality[d], 1e-9)
+
+	require.Equal(t, 1, rank(centrality, b))
+	require.Equal(t, 3, rank(centrality, a))
+
+	// Sampling all nodes yields the exact result.
+	require.InDeltaSlice(t, centrality, g.betweenness(4), 1e-9)
+
+	reach := g.reachability(a)
+	require.Equal(t, 1, reach.withinHops(1))
+	require.Equal(t, 2, reach.withinHops(2))
+	require.Equal(t, 3, reach.withinHops(3))
+	require.Equal(t, 3, reach.withinHops(4))
+	require.InDelta(t, 2.0, reach.avgDistance, 1e-9)
+
+	// All channels but the disabled one are reachable.
+	require.InDelta(t, 0.8, reach.capacityRatio, 1e-9)
+}
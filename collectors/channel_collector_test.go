@@ -1,10 +1,15 @@
 package collectors
 
 import (
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing/route"
 	"github.com/stretchr/testify/require"
 )
 
@@ -86,3 +91,149 @@ func testGetInboundFee(t *testing.T, amt, expectedFee btcutil.Amount,
 	require.NotNil(t, fee, "expected routing to be possible")
 	require.Equal(t, expectedFee, *fee)
 }
+
+// TestChannelBreakdown tests grouping of channels by commitment type,
+// visibility and channel features.
+func TestChannelBreakdown(t *testing.T) {
+	channels := []lndclient.ChannelInfo{
+		{
+			ChannelID: 1,
+			Capacity:  100_000,
+		},
+		{
+			ChannelID: 2,
+			Capacity:  200_000,
+			Private:   true,
+			ZeroConf:  true,
+		},
+		{
+			ChannelID:  3,
+			Capacity:   300_000,
+			AliasScids: []uint64{123},
+		},
+		{
+			ChannelID: 4,
+			Capacity:  400_000,
+		},
+	}
+	commitTypes := map[uint64]lnrpc.CommitmentType{
+		1: lnrpc.CommitmentType_ANCHORS,
+		2: lnrpc.CommitmentType_SIMPLE_TAPROOT,
+		3: lnrpc.CommitmentType_ANCHORS,
+	}
+
+	breakdown := newChannelBreakdown(channels, commitTypes)
+
+	require.Equal(t, &channelGroup{
+		count:    2,
+		capacity: 400_000,
+	}, breakdown.commitTypes["anchors"])
+	require.Equal(t, &channelGroup{
+		count:    1,
+		capacity: 200_000,
+	}, breakdown.commitTypes["simple_taproot"])
+
+	// Channel 4 is missing from the commitment types and should be
+	// reported as unknown.
+	require.Equal(t, &channelGroup{
+		count:    1,
+		capacity: 400_000,
+	}, breakdown.commitTypes["unknown"])
+
+	// Known commitment types without channels should still be reported.
+	require.Equal(t, &channelGroup{}, breakdown.commitTypes["legacy"])
+
+	require.Equal(t, &channelGroup{
+		count:    1,
+		capacity: 200_000,
+	}, breakdown.visibility[visibilityPrivate])
+	require.Equal(t, &channelGroup{
+		count:    3,
+		capacity: 800_000,
+	}, breakdown.visibility[visibilityPublic])
+
+	require.Equal(t, &channelGroup{
+		count:    1,
+		capacity: 200_000,
+	}, breakdown.features[featureZeroConf])
+	require.Equal(t, &channelGroup{
+		count:    1,
+		capacity: 300_000,
+	}, breakdown.features[featureScidAlias])
+}
+
+// TestPendingHtlcUsage tests that pending htlcs are attributed to the side of
+// the channel that offered them.
+func TestPendingHtlcUsage(t *testing.T) {
+	channel := lndclient.ChannelInfo{
+		PendingHtlcs: []lndclient.PendingHtlc{
+			{Incoming: true, Amount: 1000},
+			{Incoming: false, Amount: 2000},
+			{Incoming: false, Amount: 3000},
+		},
+	}
+
+	local, remote := pendingHtlcUsage(channel)
+	require.Equal(t, htlcUsage{count: 2, amount: 5_000_000}, local)
+	require.Equal(t, htlcUsage{count: 1, amount: 1_000_000}, remote)
+}
+
+// TestFormatChanID tests that channel IDs are formatted as the signed values
+// the per-channel series have always used, including alias SCIDs.
+func TestFormatChanID(t *testing.T) {
+	require.Equal(t, "1", formatChanID(1))
+
+	// Alias SCIDs start at block 16,000,000, which sets the top bit.
+	aliasScid := lnwire.ShortChannelID{BlockHeight: 16_000_000}
+	require.Equal(
+		t, "-"+strconv.FormatUint(-aliasScid.ToUint64(), 10),
+		formatChanID(aliasScid.ToUint64()),
+	)
+}
+
+// TestNewChannelInfo tests that we convert the channels returned by lnd like
+// lndclient does.
+func TestNewChannelInfo(t *testing.T) {
+	peer := route.Vertex{2}
+
+	channel, err := newChannelInfo(&lnrpc.Channel{
+		Active:        true,
+		RemotePubkey:  peer.String(),
+		ChannelPoint:  "aa:1",
+		ChanId:        123,
+		Capacity:      1_000_000,
+		LocalBalance:  600_000,
+		RemoteBalance: 390_000,
+		PendingHtlcs: []*lnrpc.HTLC{{
+			Incoming: true,
+			Amount:   10_000,
+			HashLock: make([]byte, 32),
+		}},
+		LocalConstraints: &lnrpc.ChannelConstraints{
+			CsvDelay:          144,
+			MaxPendingAmtMsat: 500_000_000,
+			MaxAcceptedHtlcs:  483,
+		},
+		Lifetime:   100,
+		Uptime:     50,
+		AliasScids: []uint64{456},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, peer, channel.PubKeyBytes)
+	require.Equal(t, btcutil.Amount(1_000_000), channel.Capacity)
+	require.Equal(t, 1, channel.NumPendingHtlcs)
+	require.True(t, channel.PendingHtlcs[0].Incoming)
+	require.Equal(t, btcutil.Amount(10_000), channel.PendingHtlcs[0].Amount)
+	require.Equal(t, &lndclient.ChannelConstraints{
+		CsvDelay:         144,
+		MaxPendingAmt:    500_000_000,
+		MaxAcceptedHtlcs: 483,
+	}, channel.LocalConstraints)
+	require.Nil(t, channel.RemoteConstraints)
+	require.Equal(t, 100*time.Second, channel.LifeTime)
+	require.Equal(t, []uint64{456}, channel.AliasScids)
+
+	_, err = newChannelInfo(&lnrpc.Channel{RemotePubkey: "invalid"})
+	require.Error(t, err)
+}
@@ -9,6 +9,10 @@ import (
 
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lntypes"
+	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+	"github.com/lightningnetwork/lnd/lnwire"
 	"github.com/lightningnetwork/lnd/routing/route"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -45,12 +49,40 @@ type ChannelsCollector struct {
 	commitWeightDesc     *prometheus.Desc
 	commitFeeDesc        *prometheus.Desc
 
+	// The following metrics track how close a channel is to the HTLC limits
+	// and the reserve and dust limits negotiated with our peer. They carry
+	// an additional side label, where local refers to the constraints
+	// that apply to HTLCs we offer and remote refers to the constraints
+	// that apply to HTLCs our peer offers.
+	htlcSlotsUsedDesc     *prometheus.Desc
+	maxAcceptedHtlcsDesc  *prometheus.Desc
+	htlcInFlightUsedDesc  *prometheus.Desc
+	maxPendingAmtMsatDesc *prometheus.Desc
+	chanReserveDesc       *prometheus.Desc
+	dustLimitDesc         *prometheus.Desc
+
 	// inboundFee is a metric that reflects the fee paid by senders on the
 	// last hop towards this node.
 	inboundFee *prometheus.Desc
 
+	// The following metrics break our channels down by commitment type,
+	// visibility and channel features.
+	commitTypeCountDesc    *prometheus.Desc
+	commitTypeCapacityDesc *prometheus.Desc
+	visibilityCountDesc    *prometheus.Desc
+	visibilityCapacityDesc *prometheus.Desc
+	featureCountDesc       *prometheus.Desc
+	featureCapacityDesc    *prometheus.Desc
+
+	// channelInfoDesc is an info-style metric that carries static channel
+	// metadata as labels, so that it can be joined on chan_id.
+	channelInfoDesc *prometheus.Desc
+
 	lnd lndclient.LightningClient
 
+	// aliases is used to resolve the aliases of our channel peers.
+	aliases *aliasCache
+
 	primaryNode *route.Vertex
 
 	// errChan is a channel that we send any errors that we encounter into.
@@ -67,13 +99,20 @@ type ChannelsCollector struct {
 }
 
 // NewChannelsCollector returns a new instance of the ChannelsCollector for the
-// target lnd client.
-func NewChannelsCollector(lnd lndclient.LightningClient, errChan chan<- error,
-	quitChan chan struct{}, cfg *MonitoringConfig) *ChannelsCollector {
+// target lnd client. The aliases of our channel peers are resolved with the
+// given alias cache.
+func NewChannelsCollector(lnd lndclient.LightningClient, aliases *aliasCache,
+	errChan chan<- error, quitChan chan struct{},
+	cfg *MonitoringConfig) *ChannelsCollector {
 
 	// Our set of labels, status should either be active or inactive. The
 	// initiator is "true" if we are the initiator, and "false" otherwise.
 	labels := []string{"chan_id", "status", "initiator", "peer"}
+
+	// The channel constraint metrics are labelled with the side the
+	// constraints apply to, which is either local or remote.
+	constraintLabels := append(labels[:len(labels):len(labels)], "side")
+
 	collector := &ChannelsCollector{
 		channelBalanceDesc: prometheus.NewDesc(
 			"lnd_channels_open_balance_sat",
@@ -180,6 +219,41 @@ func NewChannelsCollector(lnd lndclient.LightningClient, errChan chan<- error,
 			labels, nil,
 		),
 
+		htlcSlotsUsedDesc: prometheus.NewDesc(
+			"lnd_channels_htlc_slots_used_ratio",
+			"fraction of the max accepted htlcs in use within "+
+				"this channel",
+			constraintLabels, nil,
+		),
+		maxAcceptedHtlcsDesc: prometheus.NewDesc(
+			"lnd_channels_max_accepted_htlcs",
+			"max number of htlcs that can be pending within this "+
+				"channel",
+			constraintLabels, nil,
+		),
+		htlcInFlightUsedDesc: prometheus.NewDesc(
+			"lnd_channels_htlc_in_flight_used_ratio",
+			"fraction of the max pending htlc value in use "+
+				"within this channel",
+			constraintLabels, nil,
+		),
+		maxPendingAmtMsatDesc: prometheus.NewDesc(
+			"lnd_channels_max_pending_amt_msat",
+			"max htlc value that can be pending within this "+
+				"channel in msat",
+			constraintLabels, nil,
+		),
+		chanReserveDesc: prometheus.NewDesc(
+			"lnd_channels_reserve_sat",
+			"channel reserve for this channel in satoshis",
+			constraintLabels, nil,
+		),
+		dustLimitDesc: prometheus.NewDesc(
+			"lnd_channels_dust_limit_sat",
+			"dust limit for this channel in satoshis",
+			constraintLabels, nil,
+		),
+
 		// Use labels for the inbound fee for various amounts.
 		inboundFee: prometheus.NewDesc(
 			"inbound_fee",
@@ -187,7 +261,53 @@ func NewChannelsCollector(lnd lndclient.LightningClient, errChan chan<- error,
 			[]string{"amount"}, nil,
 		),
 
+		commitTypeCountDesc: prometheus.NewDesc(
+			"lnd_channels_commitment_type_total",
+			"total number of open channels per commitment type",
+			[]string{"commitment_type"}, nil,
+		),
+		commitTypeCapacityDesc: prometheus.NewDesc(
+			"lnd_channels_commitment_type_capacity_sat",
+			"total capacity of open channels per commitment type "+
+				"in satoshis",
+			[]string{"commitment_type"}, nil,
+		),
+		visibilityCountDesc: prometheus.NewDesc(
+			"lnd_channels_visibility_total",
+			"total number of private and public open channels",
+			[]string{"visibility"}, nil,
+		),
+		visibilityCapacityDesc: prometheus.NewDesc(
+			"lnd_channels_visibility_capacity_sat",
+			"total capacity of private and public open channels "+
+				"in satoshis",
+			[]string{"visibility"}, nil,
+		),
+		featureCountDesc: prometheus.NewDesc(
+			"lnd_channels_feature_total",
+			"total number of open channels using a channel feature",
+			[]string{"feature"}, nil,
+		),
+		featureCapacityDesc: prometheus.NewDesc(
+			"lnd_channels_feature_capacity_sat",
+			"total capacity of open channels using a channel "+
+				"feature in satoshis",
+			[]string{"feature"}, nil,
+		),
+
+		channelInfoDesc: prometheus.NewDesc(
+			"lnd_channel_info",
+			"static channel metadata, always set to 1",
+			[]string{
+				"chan_id", "peer", "peer_alias", "scid",
+				"chan_point", "capacity_sat", "commitment_type",
+				"private", "zero_conf", "scid_alias",
+				"open_height",
+			}, nil,
+		),
+
 		lnd:                 lnd,
+		aliases:             aliases,
 		primaryNode:         cfg.PrimaryNode,
 		closedChannelsCache: nil,
 		errChan:             errChan,
@@ -266,7 +386,23 @@ func (c *ChannelsCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.commitWeightDesc
 	ch <- c.commitFeeDesc
 
+	ch <- c.htlcSlotsUsedDesc
+	ch <- c.maxAcceptedHtlcsDesc
+	ch <- c.htlcInFlightUsedDesc
+	ch <- c.maxPendingAmtMsatDesc
+	ch <- c.chanReserveDesc
+	ch <- c.dustLimitDesc
+
 	ch <- c.inboundFee
+
+	ch <- c.commitTypeCountDesc
+	ch <- c.commitTypeCapacityDesc
+	ch <- c.visibilityCountDesc
+	ch <- c.visibilityCapacityDesc
+	ch <- c.featureCountDesc
+	ch <- c.featureCapacityDesc
+
+	ch <- c.channelInfoDesc
 }
 
 func anchorStateToString(state lndclient.ForceCloseAnchorState) string {
@@ -339,7 +475,7 @@ func (c *ChannelsCollector) Collect(ch chan<- prometheus.Metric) {
 
 	// Next, for each channel we'll export the total sum of our balances,
 	// as well as the number of pending HTLCs.
-	listChannelsResp, err := c.lnd.ListChannels(context.Background(), false, false)
+	listChannelsResp, commitTypes, err := listChannels(c.lnd)
 	if err != nil {
 		c.errChan <- fmt.Errorf("ChannelsCollector ListChannels "+
 			"failed with: %v", err)
@@ -372,7 +508,11 @@ func (c *ChannelsCollector) Collect(ch chan<- prometheus.Metric) {
 		initiator := initiatorLabel(channel)
 		peer := channel.PubKeyBytes.String()
 
-		chanIDStr := strconv.Itoa(int(channel.ChannelID))
+		chanIDStr := formatChanID(channel.ChannelID)
+
+		c.collectChannelInfo(
+			ch, channel, commitTypes[channel.ChannelID],
+		)
 
 		primaryChannel := c.primaryNode != nil &&
 			channel.PubKeyBytes == *c.primaryNode
@@ -439,6 +579,10 @@ func (c *ChannelsCollector) Collect(ch chan<- prometheus.Metric) {
 			initiator, peer,
 		)
 
+		c.collectConstraintMetrics(
+			ch, channel, chanIDStr, status, initiator, peer,
+		)
+
 		// Only record uptime if the channel has been monitored.
 		if channel.LifeTime != 0 {
 			ch <- prometheus.MustNewConstMetric(
@@ -449,6 +593,8 @@ func (c *ChannelsCollector) Collect(ch chan<- prometheus.Metric) {
 		}
 	}
 
+	c.collectChannelBreakdown(ch, listChannelsResp, commitTypes)
+
 	// Get the list of pending channels
 	pendingChannelsResp, err := c.lnd.PendingChannels(context.Background())
 	if err != nil {
@@ -570,6 +716,396 @@ func (c *ChannelsCollector) Collect(ch chan<- prometheus.Metric) {
 	}
 }
 
+const (
+	// constraintsLocal and constraintsRemote are the label values we use
+	// for the side that a set of channel constraints applies to.
+	constraintsLocal  = "local"
+	constraintsRemote = "remote"
+)
+
+// htlcUsage is the number and value of the pending htlcs offered by one side
+// of a channel.
+type htlcUsage struct {
+	count  int
+	amount lnwire.MilliSatoshi
+}
+
+// pendingHtlcUsage returns the pending htlcs offered by us (outgoing) and by
+// our peer (incoming) in the given channel.
+func pendingHtlcUsage(channel lndclient.ChannelInfo) (htlcUsage, htlcUsage) {
+	var local, remote htlcUsage
+	for _, htlc := range channel.PendingHtlcs {
+		usage := &local
+		if htlc.Incoming {
+			usage = &remote
+		}
+
+		usage.count++
+		usage.amount += lnwire.NewMSatFromSatoshis(htlc.Amount)
+	}
+
+	return local, remote
+}
+
+// collectConstraintMetrics exports the htlc limit utilization as well as the
+// reserve and dust limits of a channel for both sides of the channel.
+func (c *ChannelsCollector) collectConstraintMetrics(
+	ch chan<- prometheus.Metric, channel lndclient.ChannelInfo,
+	chanIDStr, status, initiator, peer string) {
+
+	localUsage, remoteUsage := pendingHtlcUsage(channel)
+
+	collect := func(constraints *lndclient.ChannelConstraints,
+		usage htlcUsage, side string) {
+
+		// Older lnd versions may not report the constraints at all.
+		if constraints == nil {
+			return
+		}
+
+		labels := []string{chanIDStr, status, initiator, peer, side}
+
+		if constraints.MaxAcceptedHtlcs > 0 {
+			ch <- prometheus.MustNewConstMetric(
+				c.htlcSlotsUsedDesc, prometheus.GaugeValue,
+				float64(usage.count)/
+					float64(constraints.MaxAcceptedHtlcs),
+				labels...,
+			)
+		}
+		if constraints.MaxPendingAmt > 0 {
+			ch <- prometheus.MustNewConstMetric(
+				c.htlcInFlightUsedDesc, prometheus.GaugeValue,
+				float64(usage.amount)/
+					float64(constraints.MaxPendingAmt),
+				labels...,
+			)
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			c.maxAcceptedHtlcsDesc, prometheus.GaugeValue,
+			float64(constraints.MaxAcceptedHtlcs), labels...,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.maxPendingAmtMsatDesc, prometheus.GaugeValue,
+			float64(constraints.MaxPendingAmt), labels...,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.chanReserveDesc, prometheus.GaugeValue,
+			float64(constraints.Reserve), labels...,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.dustLimitDesc, prometheus.GaugeValue,
+			float64(constraints.DustLimit), labels...,
+		)
+	}
+
+	// The local constraints limit the htlcs that we can offer to our peer,
+	// while the remote constraints limit the htlcs our peer can offer us.
+	collect(channel.LocalConstraints, localUsage, constraintsLocal)
+	collect(channel.RemoteConstraints, remoteUsage, constraintsRemote)
+}
+
+const (
+	// visibilityPrivate and visibilityPublic are the label values we use
+	// for the visibility of a channel.
+	visibilityPrivate = "private"
+	visibilityPublic  = "public"
+
+	// featureZeroConf and featureScidAlias are the label values we use for
+	// channels that make use of zero-conf and SCID aliases respectively.
+	featureZeroConf  = "zero_conf"
+	featureScidAlias = "scid_alias"
+)
+
+// channelGroup is the number and total capacity of a group of channels.
+type channelGroup struct {
+	count    int
+	capacity btcutil.Amount
+}
+
+// add adds a channel to the group.
+func (g *channelGroup) add(capacity btcutil.Amount) {
+	g.count++
+	g.capacity += capacity
+}
+
+// channelBreakdown groups our open channels by commitment type, visibility and
+// channel features.
+type channelBreakdown struct {
+	commitTypes map[string]*channelGroup
+	visibility  map[string]*channelGroup
+	features    map[string]*channelGroup
+}
+
+// newChannelBreakdown computes the channel breakdown for the given channels.
+// All known label values are initialized with zero, so that we don't end up
+// with stale series once the last channel of a group is closed.
+func newChannelBreakdown(channels []lndclient.ChannelInfo,
+	commitTypes map[uint64]lnrpc.CommitmentType) *channelBreakdown {
+
+	breakdown := &channelBreakdown{
+		commitTypes: make(map[string]*channelGroup),
+		visibility: map[string]*channelGroup{
+			visibilityPrivate: {},
+			visibilityPublic:  {},
+		},
+		features: map[string]*channelGroup{
+			featureZeroConf:  {},
+			featureScidAlias: {},
+		},
+	}
+	for _, label := range commitmentTypeLabelMap {
+		breakdown.commitTypes[label] = &channelGroup{}
+	}
+
+	for _, channel := range channels {
+		commitType := commitmentTypeLabel(commitTypes[channel.ChannelID])
+		if _, ok := breakdown.commitTypes[commitType]; !ok {
+			breakdown.commitTypes[commitType] = &channelGroup{}
+		}
+		breakdown.commitTypes[commitType].add(channel.Capacity)
+
+		visibility := visibilityPublic
+		if channel.Private {
+			visibility = visibilityPrivate
+		}
+		breakdown.visibility[visibility].add(channel.Capacity)
+
+		if channel.ZeroConf {
+			breakdown.features[featureZeroConf].add(channel.Capacity)
+		}
+		if len(channel.AliasScids) > 0 {
+			breakdown.features[featureScidAlias].add(
+				channel.Capacity,
+			)
+		}
+	}
+
+	return breakdown
+}
+
+// collectChannelBreakdown exports the number and capacity of our channels per
+// commitment type, visibility and channel feature.
+func (c *ChannelsCollector) collectChannelBreakdown(ch chan<- prometheus.Metric,
+	channels []lndclient.ChannelInfo,
+	commitTypes map[uint64]lnrpc.CommitmentType) {
+
+	breakdown := newChannelBreakdown(channels, commitTypes)
+
+	collectGroups := func(countDesc, capacityDesc *prometheus.Desc,
+		groups map[string]*channelGroup) {
+
+		for label, group := range groups {
+			ch <- prometheus.MustNewConstMetric(
+				countDesc, prometheus.GaugeValue,
+				float64(group.count), label,
+			)
+			ch <- prometheus.MustNewConstMetric(
+				capacityDesc, prometheus.GaugeValue,
+				float64(group.capacity), label,
+			)
+		}
+	}
+
+	collectGroups(
+		c.commitTypeCountDesc, c.commitTypeCapacityDesc,
+		breakdown.commitTypes,
+	)
+	collectGroups(
+		c.visibilityCountDesc, c.visibilityCapacityDesc,
+		breakdown.visibility,
+	)
+	collectGroups(
+		c.featureCountDesc, c.featureCapacityDesc, breakdown.features,
+	)
+}
+
+// collectChannelInfo exports the lnd_channel_info series for a channel.
+func (c *ChannelsCollector) collectChannelInfo(ch chan<- prometheus.Metric,
+	channel lndclient.ChannelInfo, commitType lnrpc.CommitmentType) {
+
+	// For zero-conf channels the channel ID is an alias until the funding
+	// transaction confirms, so we only report the real SCID once we have
+	// it.
+	confirmedScid := channel.ChannelID
+	if channel.ZeroConf {
+		confirmedScid = channel.ZeroConfScid
+	}
+
+	var scid, openHeight string
+	if confirmedScid != 0 {
+		shortChanID := lnwire.NewShortChanIDFromInt(confirmedScid)
+		scid = shortChanID.AltString()
+		openHeight = strconv.FormatUint(
+			uint64(shortChanID.BlockHeight), 10,
+		)
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.channelInfoDesc, prometheus.GaugeValue, 1,
+		formatChanID(channel.ChannelID),
+		channel.PubKeyBytes.String(),
+		c.aliases.get(channel.PubKeyBytes),
+		scid, channel.ChannelPoint,
+		strconv.FormatInt(int64(channel.Capacity), 10),
+		commitmentTypeLabel(commitType),
+		strconv.FormatBool(channel.Private),
+		strconv.FormatBool(channel.ZeroConf),
+		strconv.FormatBool(len(channel.AliasScids) > 0),
+		openHeight,
+	)
+}
+
+// listChannels returns our open channels along with their commitment types,
+// keyed by channel ID. Since the lndclient channel info doesn't include the
+// commitment type, we use the raw lnrpc client and convert the channels
+// ourselves, so that both come from the same snapshot of our channels.
+func listChannels(lnd lndclient.LightningClient) ([]lndclient.ChannelInfo,
+	map[uint64]lnrpc.CommitmentType, error) {
+
+	rpcCtx, timeout, client := lnd.RawClientWithMacAuth(
+		context.Background(),
+	)
+	rpcCtx, cancel := context.WithTimeout(rpcCtx, timeout)
+	defer cancel()
+
+	resp, err := client.ListChannels(rpcCtx, &lnrpc.ListChannelsRequest{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	channels := make([]lndclient.ChannelInfo, len(resp.Channels))
+	commitTypes := make(map[uint64]lnrpc.CommitmentType, len(resp.Channels))
+	for i, channel := range resp.Channels {
+		channelInfo, err := newChannelInfo(channel)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		channels[i] = *channelInfo
+		commitTypes[channel.ChanId] = channel.CommitmentType
+	}
+
+	return channels, commitTypes, nil
+}
+
+// newChannelInfo converts a channel returned by lnd into the lndclient channel
+// info, like lndclient's ListChannels does. We leave out the close address,
+// which we don't export.
+func newChannelInfo(channel *lnrpc.Channel) (*lndclient.ChannelInfo, error) {
+	remoteVertex, err := route.NewVertexFromStr(channel.RemotePubkey)
+	if err != nil {
+		return nil, err
+	}
+
+	chanInfo := &lndclient.ChannelInfo{
+		ChannelPoint:     channel.ChannelPoint,
+		Active:           channel.Active,
+		ChannelID:        channel.ChanId,
+		PubKeyBytes:      remoteVertex,
+		Capacity:         btcutil.Amount(channel.Capacity),
+		LocalBalance:     btcutil.Amount(channel.LocalBalance),
+		RemoteBalance:    btcutil.Amount(channel.RemoteBalance),
+		UnsettledBalance: btcutil.Amount(channel.UnsettledBalance),
+		Initiator:        channel.Initiator,
+		Private:          channel.Private,
+		ChanStatusFlags:  channel.ChanStatusFlags,
+		NumPendingHtlcs:  len(channel.PendingHtlcs),
+		TotalSent:        btcutil.Amount(channel.TotalSatoshisSent),
+		TotalReceived:    btcutil.Amount(channel.TotalSatoshisReceived),
+		NumUpdates:       channel.NumUpdates,
+		FeePerKw:         chainfee.SatPerKWeight(channel.FeePerKw),
+		CommitWeight:     channel.CommitWeight,
+		CommitFee:        btcutil.Amount(channel.CommitFee),
+		LifeTime:         time.Second * time.Duration(channel.Lifetime),
+		Uptime:           time.Second * time.Duration(channel.Uptime),
+		LocalConstraints: newChannelConstraints(
+			channel.LocalConstraints,
+		),
+		RemoteConstraints: newChannelConstraints(
+			channel.RemoteConstraints,
+		),
+		ZeroConf:          channel.ZeroConf,
+		ZeroConfScid:      channel.ZeroConfConfirmedScid,
+		CustomChannelData: channel.CustomChannelData,
+	}
+
+	chanInfo.AliasScids = make([]uint64, len(channel.AliasScids))
+	copy(chanInfo.AliasScids, channel.AliasScids)
+
+	chanInfo.PendingHtlcs = make(
+		[]lndclient.PendingHtlc, len(channel.PendingHtlcs),
+	)
+	for i, htlc := range channel.PendingHtlcs {
+		hash, err := lntypes.MakeHash(htlc.HashLock)
+		if err != nil {
+			return nil, err
+		}
+
+		chanInfo.PendingHtlcs[i] = lndclient.PendingHtlc{
+			Incoming:  htlc.Incoming,
+			Amount:    btcutil.Amount(htlc.Amount),
+			Hash:      hash,
+			Expiry:    htlc.ExpirationHeight,
+			HtlcIndex: htlc.HtlcIndex,
+			ForwardingChannel: lnwire.NewShortChanIDFromInt(
+				htlc.ForwardingChannel,
+			),
+			ForwardingIndex: htlc.ForwardingHtlcIndex,
+		}
+	}
+
+	return chanInfo, nil
+}
+
+// newChannelConstraints converts the constraints of a channel returned by lnd
+// into their lndclient form.
+func newChannelConstraints(
+	constraints *lnrpc.ChannelConstraints) *lndclient.ChannelConstraints {
+
+	if constraints == nil {
+		return nil
+	}
+
+	return &lndclient.ChannelConstraints{
+		CsvDelay:  constraints.CsvDelay,
+		Reserve:   btcutil.Amount(constraints.ChanReserveSat),
+		DustLimit: btcutil.Amount(constraints.DustLimitSat),
+		MaxPendingAmt: lnwire.MilliSatoshi(
+			constraints.MaxPendingAmtMsat,
+		),
+		MinHtlc:          lnwire.MilliSatoshi(constraints.MinHtlcMsat),
+		MaxAcceptedHtlcs: constraints.MaxAcceptedHtlcs,
+	}
+}
+
+var commitmentTypeLabelMap = map[lnrpc.CommitmentType]string{
+	lnrpc.CommitmentType_LEGACY:                 "legacy",
+	lnrpc.CommitmentType_STATIC_REMOTE_KEY:      "static_remote_key",
+	lnrpc.CommitmentType_ANCHORS:                "anchors",
+	lnrpc.CommitmentType_SCRIPT_ENFORCED_LEASE:  "script_enforced_lease",
+	lnrpc.CommitmentType_SIMPLE_TAPROOT:         "simple_taproot",
+	lnrpc.CommitmentType_SIMPLE_TAPROOT_OVERLAY: "simple_taproot_overlay",
+}
+
+// commitmentTypeLabel returns the label value we use for a commitment type.
+func commitmentTypeLabel(commitType lnrpc.CommitmentType) string {
+	label, ok := commitmentTypeLabelMap[commitType]
+	if !ok {
+		return "unknown"
+	}
+
+	return label
+}
+
+// formatChanID returns the chan_id label value of a channel. All per-channel
+// series must use it so that they can be joined on chan_id, including alias
+// SCIDs that have their top bit set.
+func formatChanID(chanID uint64) string {
+	return strconv.Itoa(int(chanID))
+}
+
 var closeTypeLabelMap = map[lndclient.CloseType]string{
 	lndclient.CloseTypeCooperative:      "cooperative",
 	lndclient.CloseTypeLocalForce:       "local_force",
@@ -0,0 +1,184 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// minFeeEstimateConfTarget is the smallest confirmation target lnd estimates
+// fees for.
+const minFeeEstimateConfTarget = 2
+
+// FeeEstimatesConfig is the set of configuration data that specifies which
+// fee estimates of lnd are exported.
+type FeeEstimatesConfig struct {
+	// Disable disables the collection of fee estimate metrics.
+	Disable bool `long:"disable" description:"Do not collect fee estimate metrics"`
+
+	// ConfTargets is the set of confirmation targets to export lnd's fee
+	// estimates for.
+	ConfTargets []uint32 `long:"conftarget" description:"A confirmation target to export lnd's fee estimate for, must be at least 2 since lnd doesn't estimate fees for the next block, can be specified multiple times"`
+}
+
+// DefaultFeeEstimatesConfig returns the default fee estimates configuration.
+func DefaultFeeEstimatesConfig() *FeeEstimatesConfig {
+	return &FeeEstimatesConfig{
+		ConfTargets: []uint32{2, 3, 6, 12, 144},
+	}
+}
+
+// Validate checks that the fee estimates configuration is sane.
+func (c *FeeEstimatesConfig) Validate() error {
+	if c.Disable {
+		return nil
+	}
+
+	if len(c.ConfTargets) == 0 {
+		return fmt.Errorf("at least one fee estimate confirmation " +
+			"target is required")
+	}
+
+	seen := make(map[uint32]struct{}, len(c.ConfTargets))
+	for _, target := range c.ConfTargets {
+		if target < minFeeEstimateConfTarget {
+			return fmt.Errorf("fee estimate confirmation target "+
+				"must be at least %d, got %d",
+				minFeeEstimateConfTarget, target)
+		}
+
+		if _, ok := seen[target]; ok {
+			return fmt.Errorf("duplicate fee estimate "+
+				"confirmation target %d", target)
+		}
+		seen[target] = struct{}{}
+	}
+
+	return nil
+}
+
+// satPerVByte converts a fee rate to sat/vbyte without rounding it.
+func satPerVByte(feeRate chainfee.SatPerKWeight) float64 {
+	return float64(feeRate) * 4 / 1000
+}
+
+// FeeEstimateCollector is a collector that exports lnd's fee estimates for a
+// set of confirmation targets, and compares the fee rates of our channels'
+// commitment transactions to them.
+type FeeEstimateCollector struct {
+	lnd *lndclient.LndServices
+
+	// confTargets are the confirmation targets we export fee estimates
+	// for, in ascending order.
+	confTargets []uint32
+
+	feeEstimateDesc    *prometheus.Desc
+	commitFeeRatioDesc *prometheus.Desc
+
+	// errChan is a channel that we send any errors that we encounter into.
+	// This channel should be buffered so that it does not block sends.
+	errChan chan<- error
+}
+
+// NewFeeEstimateCollector returns a new instance of the FeeEstimateCollector.
+func NewFeeEstimateCollector(lnd *lndclient.LndServices,
+	cfg *FeeEstimatesConfig, errChan chan<- error) *FeeEstimateCollector {
+
+	confTargets := make([]uint32, len(cfg.ConfTargets))
+	copy(confTargets, cfg.ConfTargets)
+	sort.Slice(confTargets, func(i, j int) bool {
+		return confTargets[i] < confTargets[j]
+	})
+
+	return &FeeEstimateCollector{
+		lnd:         lnd,
+		confTargets: confTargets,
+		feeEstimateDesc: prometheus.NewDesc(
+			"lnd_fee_estimate_sat_per_vbyte",
+			"lnd's fee estimate for a confirmation target",
+			[]string{"conf_target"}, nil,
+		),
+		commitFeeRatioDesc: prometheus.NewDesc(
+			"lnd_channel_commit_fee_rate_estimate_ratio",
+			"ratio of the fee rate of the channel's commitment "+
+				"transaction to lnd's fee estimate for the "+
+				"smallest confirmation target",
+			[]string{"chan_id", "peer", "conf_target"}, nil,
+		),
+		errChan: errChan,
+	}
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once the
+// last descriptor has been sent.
+//
+// NOTE: Part of the prometheus.Collector interface.
+func (f *FeeEstimateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- f.feeEstimateDesc
+	ch <- f.commitFeeRatioDesc
+}
+
+// Collect is called by the Prometheus registry when collecting metrics.
+//
+// NOTE: Part of the prometheus.Collector interface.
+func (f *FeeEstimateCollector) Collect(ch chan<- prometheus.Metric) {
+	estimates := make([]chainfee.SatPerKWeight, len(f.confTargets))
+	for i, target := range f.confTargets {
+		feeRate, err := f.lnd.WalletKit.EstimateFeeRate(
+			context.Background(), int32(target),
+		)
+		if err != nil {
+			f.errChan <- fmt.Errorf("FeeEstimateCollector "+
+				"EstimateFeeRate failed with: %v", err)
+			return
+		}
+		estimates[i] = feeRate
+
+		ch <- prometheus.MustNewConstMetric(
+			f.feeEstimateDesc, prometheus.GaugeValue,
+			satPerVByte(feeRate), strconv.Itoa(int(target)),
+		)
+	}
+
+	// We compare our commitments to the estimate of the most urgent
+	// target, since that's what a force close would need to pay to
+	// confirm quickly.
+	channels, err := f.lnd.Client.ListChannels(
+		context.Background(), false, false,
+	)
+	if err != nil {
+		f.errChan <- fmt.Errorf("FeeEstimateCollector ListChannels "+
+			"failed with: %v", err)
+		return
+	}
+
+	f.collectCommitFeeRatios(ch, channels, f.confTargets[0], estimates[0])
+}
+
+// collectCommitFeeRatios exports the ratio of the commitment fee rate of each
+// of the given channels to the given fee estimate.
+func (f *FeeEstimateCollector) collectCommitFeeRatios(
+	ch chan<- prometheus.Metric, channels []lndclient.ChannelInfo,
+	confTarget uint32, estimate chainfee.SatPerKWeight) {
+
+	// Without an estimate, there is nothing to compare against.
+	if estimate == 0 {
+		return
+	}
+
+	target := strconv.Itoa(int(confTarget))
+	for _, channel := range channels {
+		ch <- prometheus.MustNewConstMetric(
+			f.commitFeeRatioDesc, prometheus.GaugeValue,
+			float64(channel.FeePerKw)/float64(estimate),
+			formatChanID(channel.ChannelID),
+			channel.PubKeyBytes.String(), target,
+		)
+	}
+}
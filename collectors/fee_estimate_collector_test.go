@@ -0,0 +1,58 @@
+package collectors
+
+import (
+	"testing"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFeeEstimatesConfig tests the validation of fee estimate configs.
+func TestFeeEstimatesConfig(t *testing.T) {
+	require.NoError(t, DefaultFeeEstimatesConfig().Validate())
+
+	cfg := &FeeEstimatesConfig{ConfTargets: []uint32{1, 6}}
+	require.ErrorContains(t, cfg.Validate(), "at least 2")
+
+	cfg = &FeeEstimatesConfig{ConfTargets: []uint32{6, 6}}
+	require.ErrorContains(t, cfg.Validate(), "duplicate")
+
+	cfg = &FeeEstimatesConfig{}
+	require.Error(t, cfg.Validate())
+
+	cfg.Disable = true
+	require.NoError(t, cfg.Validate())
+}
+
+// TestCommitFeeRatios tests that we compare our commitment fee rates to the
+// fee estimate of the most urgent confirmation target.
+func TestCommitFeeRatios(t *testing.T) {
+	collector := NewFeeEstimateCollector(
+		nil, &FeeEstimatesConfig{ConfTargets: []uint32{144, 3}},
+		make(chan error, 1),
+	)
+	require.Equal(t, []uint32{3, 144}, collector.confTargets)
+
+	channels := []lndclient.ChannelInfo{
+		{ChannelID: 1, PubKeyBytes: route.Vertex{1}, FeePerKw: 253},
+		{ChannelID: 2, PubKeyBytes: route.Vertex{2}, FeePerKw: 5000},
+	}
+
+	ch := make(chan prometheus.Metric, 10)
+	collector.collectCommitFeeRatios(ch, channels, 3, 2500)
+
+	// Without an estimate, we don't export any ratios.
+	collector.collectCommitFeeRatios(ch, channels, 3, 0)
+	close(ch)
+
+	series := collectMetrics(t, ch)
+	require.Len(t, series[collector.commitFeeRatioDesc], 2)
+	require.Equal(t, map[string]float64{
+		"1": 253.0 / 2500,
+		"2": 2,
+	}, valuesByLabel(series[collector.commitFeeRatioDesc], "chan_id"))
+
+	require.Equal(t, 1.012, satPerVByte(253))
+}
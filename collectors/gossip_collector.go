@@ -0,0 +1,343 @@
+package collectors
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// graphResyncInterval is the interval at which the gossip monitor re-fetches
+// the full graph from lnd, to correct for any drift of its in-memory graph
+// (e.g. nodes that lnd pruned from its graph).
+const graphResyncInterval = time.Hour
+
+// graphSource provides a snapshot of the public channel graph. It is
+// implemented by lndclient's LightningClient, which fetches the full graph on
+// every call, and by the gossip monitor, which serves its in-memory graph.
+type graphSource interface {
+	// DescribeGraph returns the channel graph.
+	DescribeGraph(ctx context.Context, includeUnannounced bool) (
+		*lndclient.Graph, error)
+}
+
+// gossipMonitor counts the graph topology updates we receive from lnd and
+// uses them to maintain an in-memory copy of the channel graph. This allows
+// the graph collector to compute its metrics without downloading the full
+// graph on every scrape.
+type gossipMonitor struct {
+	lnd lndclient.LightningClient
+
+	// self is our own node's pubkey.
+	self route.Vertex
+
+	// nodes and edges are our in-memory copy of the channel graph. Both
+	// maps are guarded by graphMtx.
+	nodes    map[route.Vertex]lndclient.Node
+	edges    map[uint64]*lndclient.ChannelEdge
+	graphMtx sync.RWMutex
+
+	// nodeUpdateCounter counts the node announcements we've received.
+	nodeUpdateCounter prometheus.Counter
+
+	// channelUpdateCounter counts the channel updates we've received.
+	channelUpdateCounter prometheus.Counter
+
+	// channelCloseCounter counts the closed channel notifications we've
+	// received.
+	channelCloseCounter prometheus.Counter
+
+	// lastSyncGauge tracks the time of the last full graph sync.
+	lastSyncGauge prometheus.Gauge
+
+	// quit is closed to signal that we need to shutdown.
+	quit chan struct{}
+
+	wg sync.WaitGroup
+
+	// errChan is a channel that we send any errors that we encounter into.
+	// This channel should be buffered so that it does not block sends.
+	errChan chan<- error
+}
+
+// A compile time check to ensure that gossipMonitor implements graphSource.
+var _ graphSource = (*gossipMonitor)(nil)
+
+// newGossipMonitor creates a new gossip monitor.
+func newGossipMonitor(lnd lndclient.LightningClient, self route.Vertex,
+	errChan chan error) *gossipMonitor {
+
+	return &gossipMonitor{
+		lnd:   lnd,
+		self:  self,
+		nodes: make(map[route.Vertex]lndclient.Node),
+		edges: make(map[uint64]*lndclient.ChannelEdge),
+		nodeUpdateCounter: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "lnd",
+			Subsystem: "gossip",
+			Name:      "node_announcements_total",
+			Help:      "count of node announcements received",
+		}),
+		channelUpdateCounter: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: "lnd",
+				Subsystem: "gossip",
+				Name:      "channel_updates_total",
+				Help:      "count of channel updates received",
+			},
+		),
+		channelCloseCounter: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: "lnd",
+				Subsystem: "gossip",
+				Name:      "closed_channels_total",
+				Help: "count of closed channel notifications " +
+					"received",
+			},
+		),
+		lastSyncGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "lnd",
+			Subsystem: "gossip",
+			Name:      "last_graph_sync_timestamp_seconds",
+			Help: "timestamp of the last full sync of the " +
+				"in-memory graph",
+		}),
+		quit:    make(chan struct{}),
+		errChan: errChan,
+	}
+}
+
+// start subscribes to graph updates, performs the initial sync of our
+// in-memory graph and begins the main event loop of the gossip monitor.
+func (g *gossipMonitor) start() error {
+	gossipLogger.Info("Starting gossip monitor")
+
+	// We're restarted whenever lnd was unavailable, so we need a fresh
+	// quit channel each time.
+	g.quit = make(chan struct{})
+
+	// Create a context to subscribe to updates and cancel it on exit so
+	// that lnd can cancel the stream. We subscribe before fetching the
+	// graph so that we don't miss any updates in between.
+	ctx, cancel := context.WithCancel(context.Background())
+
+	graphUpdates, graphErrChan, err := g.lnd.SubscribeGraph(ctx)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	if err := g.syncGraph(); err != nil {
+		cancel()
+		return err
+	}
+
+	g.wg.Add(1)
+	go func() {
+		defer func() {
+			cancel()
+			g.wg.Done()
+		}()
+
+		ticker := time.NewTicker(graphResyncInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case update, ok := <-graphUpdates:
+				if !ok {
+					err := errors.New("graph update " +
+						"stream terminated")
+					sendError(g.errChan, g.quit, err)
+					return
+				}
+
+				g.processGraphUpdate(update)
+
+			case err, ok := <-graphErrChan:
+				sendError(g.errChan, g.quit, fmt.Errorf(
+					"graph update stream exited: %v, "+
+						"closed: %v", err, ok,
+				))
+				return
+
+			case <-ticker.C:
+				if err := g.syncGraph(); err != nil {
+					sendError(g.errChan, g.quit, err)
+					return
+				}
+
+			case <-g.quit:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// stop sends the gossip monitor's goroutines the instruction to shutdown and
+// waits for them to exit.
+func (g *gossipMonitor) stop() {
+	gossipLogger.Info("Stopping gossip monitor")
+
+	close(g.quit)
+	g.wg.Wait()
+}
+
+// collectors returns all of the collectors that the gossip monitor uses.
+func (g *gossipMonitor) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		g.nodeUpdateCounter, g.channelUpdateCounter,
+		g.channelCloseCounter, g.lastSyncGauge,
+	}
+}
+
+// syncGraph replaces our in-memory graph with the full graph from lnd.
+func (g *gossipMonitor) syncGraph() error {
+	graph, err := g.lnd.DescribeGraph(context.Background(), false)
+	if err != nil {
+		return fmt.Errorf("gossip monitor DescribeGraph failed "+
+			"with: %v", err)
+	}
+
+	nodes := make(map[route.Vertex]lndclient.Node, len(graph.Nodes))
+	for _, node := range graph.Nodes {
+		nodes[node.PubKey] = node
+	}
+
+	edges := make(map[uint64]*lndclient.ChannelEdge, len(graph.Edges))
+	for i := range graph.Edges {
+		edges[graph.Edges[i].ChannelID] = &graph.Edges[i]
+	}
+
+	g.graphMtx.Lock()
+	g.nodes = nodes
+	g.edges = edges
+	g.graphMtx.Unlock()
+
+	g.lastSyncGauge.SetToCurrentTime()
+
+	gossipLogger.Debugf("Synced in-memory graph with %d nodes and %d edges",
+		len(nodes), len(edges))
+
+	return nil
+}
+
+// processGraphUpdate counts the updates contained in a graph topology update
+// and applies them to our in-memory graph.
+func (g *gossipMonitor) processGraphUpdate(
+	update *lndclient.GraphTopologyUpdate) {
+
+	g.nodeUpdateCounter.Add(float64(len(update.NodeUpdates)))
+	g.channelUpdateCounter.Add(float64(len(update.ChannelEdgeUpdates)))
+	g.channelCloseCounter.Add(float64(len(update.ChannelCloseUpdates)))
+
+	g.graphMtx.Lock()
+	defer g.graphMtx.Unlock()
+
+	for _, nodeUpdate := range update.NodeUpdates {
+		// The update doesn't carry the announcement's timestamp, so we
+		// use the time we received it instead.
+		g.nodes[nodeUpdate.IdentityKey] = lndclient.Node{
+			PubKey:     nodeUpdate.IdentityKey,
+			LastUpdate: time.Now(),
+			Alias:      nodeUpdate.Alias,
+			Color:      nodeUpdate.Color,
+			Features:   nodeUpdate.Features,
+			Addresses:  nodeUpdate.Addresses,
+		}
+	}
+
+	for _, edgeUpdate := range update.ChannelEdgeUpdates {
+		g.applyEdgeUpdate(edgeUpdate)
+	}
+
+	for _, closeUpdate := range update.ChannelCloseUpdates {
+		delete(g.edges, closeUpdate.ChannelID.ToUint64())
+	}
+}
+
+// applyEdgeUpdate applies a channel update to our in-memory graph, adding the
+// channel if we don't know about it yet. The caller must hold the graph mutex.
+func (g *gossipMonitor) applyEdgeUpdate(update lndclient.ChannelEdgeUpdate) {
+	chanID := update.ChannelID.ToUint64()
+
+	edge, ok := g.edges[chanID]
+	if !ok {
+		// lnd also notifies us about updates of our own private and
+		// not yet announced channels, which aren't part of the public
+		// graph. Since the updates don't tell them apart from our
+		// announced channels, we skip unknown channels of our own
+		// node. Our newly announced channels are added by the next
+		// full sync instead.
+		if update.AdvertisingNode == g.self ||
+			update.ConnectingNode == g.self {
+
+			return
+		}
+
+		// Like lnd, we order the nodes of a channel by their pubkey.
+		node1, node2 := update.AdvertisingNode, update.ConnectingNode
+		if bytes.Compare(node1[:], node2[:]) > 0 {
+			node1, node2 = node2, node1
+		}
+
+		edge = &lndclient.ChannelEdge{
+			ChannelID:    chanID,
+			ChannelPoint: update.ChannelPoint.String(),
+			Capacity:     update.Capacity,
+			Node1:        node1,
+			Node2:        node2,
+		}
+		g.edges[chanID] = edge
+
+		// lnd adds nodes it hasn't received an announcement for yet
+		// to its graph as well, so we do the same to keep our node
+		// count in line with it.
+		for _, node := range []route.Vertex{node1, node2} {
+			if _, ok := g.nodes[node]; !ok {
+				g.nodes[node] = lndclient.Node{PubKey: node}
+			}
+		}
+	}
+
+	// We always replace the policy rather than modifying it, since
+	// snapshots of the graph share policies with our in-memory graph.
+	policy := update.RoutingPolicy
+	if update.AdvertisingNode == edge.Node1 {
+		edge.Node1Policy = &policy
+	} else {
+		edge.Node2Policy = &policy
+	}
+}
+
+// DescribeGraph returns a snapshot of our in-memory graph. Since we only
+// track the public graph, includeUnannounced is ignored.
+//
+// NOTE: Part of the graphSource interface.
+func (g *gossipMonitor) DescribeGraph(_ context.Context, _ bool) (
+	*lndclient.Graph, error) {
+
+	g.graphMtx.RLock()
+	defer g.graphMtx.RUnlock()
+
+	graph := &lndclient.Graph{
+		Nodes: make([]lndclient.Node, 0, len(g.nodes)),
+		Edges: make([]lndclient.ChannelEdge, 0, len(g.edges)),
+	}
+	for _, node := range g.nodes {
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	for _, edge := range g.edges {
+		graph.Edges = append(graph.Edges, *edge)
+	}
+
+	return graph, nil
+}
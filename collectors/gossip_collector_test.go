@@ -0,0 +1,111 @@
+package collectors
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGossipMonitorProcessGraphUpdate tests that graph topology updates are
+// counted and applied to the in-memory graph.
+func TestGossipMonitorProcessGraphUpdate(t *testing.T) {
+	var (
+		self   = route.Vertex{3}
+		nodeA  = route.Vertex{1}
+		nodeB  = route.Vertex{2}
+		chanID = lnwire.NewShortChanIDFromInt(100)
+	)
+
+	monitor := newGossipMonitor(nil, self, make(chan error, 1))
+
+	// Node B announces a channel to node A, which should add the channel
+	// with node A as node 1, as well as shell nodes for both ends.
+	monitor.processGraphUpdate(&lndclient.GraphTopologyUpdate{
+		ChannelEdgeUpdates: []lndclient.ChannelEdgeUpdate{{
+			ChannelID: chanID,
+			Capacity:  1_000_000,
+			RoutingPolicy: lndclient.RoutingPolicy{
+				FeeRateMilliMsat: 10,
+			},
+			AdvertisingNode: nodeB,
+			ConnectingNode:  nodeA,
+		}},
+	})
+
+	graph, err := monitor.DescribeGraph(context.Background(), false)
+	require.NoError(t, err)
+	require.Len(t, graph.Nodes, 2)
+	require.Len(t, graph.Edges, 1)
+
+	edge := graph.Edges[0]
+	require.Equal(t, chanID.ToUint64(), edge.ChannelID)
+	require.Equal(t, nodeA, edge.Node1)
+	require.Equal(t, nodeB, edge.Node2)
+	require.Nil(t, edge.Node1Policy)
+	require.EqualValues(t, 10, edge.Node2Policy.FeeRateMilliMsat)
+
+	// A node announcement replaces the shell node, and a close removes
+	// the channel again.
+	monitor.processGraphUpdate(&lndclient.GraphTopologyUpdate{
+		NodeUpdates: []lndclient.NodeUpdate{{
+			IdentityKey: nodeA,
+			Alias:       "alice",
+		}},
+		ChannelCloseUpdates: []lndclient.ChannelCloseUpdate{{
+			ChannelID: chanID,
+		}},
+	})
+
+	graph, err = monitor.DescribeGraph(context.Background(), false)
+	require.NoError(t, err)
+	require.Len(t, graph.Nodes, 2)
+	require.Empty(t, graph.Edges)
+	require.Equal(t, "alice", monitor.nodes[nodeA].Alias)
+
+	// The policy of the snapshot we took earlier must not be affected by
+	// any later updates.
+	require.EqualValues(t, 10, edge.Node2Policy.FeeRateMilliMsat)
+
+	// Updates of channels of our own node that aren't in the public graph
+	// are counted, but not added to our in-memory graph.
+	monitor.processGraphUpdate(&lndclient.GraphTopologyUpdate{
+		ChannelEdgeUpdates: []lndclient.ChannelEdgeUpdate{{
+			ChannelID:       lnwire.NewShortChanIDFromInt(200),
+			Capacity:        500_000,
+			AdvertisingNode: self,
+			ConnectingNode:  nodeA,
+		}},
+	})
+	require.Empty(t, monitor.edges)
+	require.NotContains(t, monitor.nodes, self)
+
+	// Once our channel is part of the public graph, its updates are
+	// applied like any other.
+	monitor.edges[200] = &lndclient.ChannelEdge{
+		ChannelID: 200,
+		Node1:     nodeA,
+		Node2:     self,
+	}
+	monitor.processGraphUpdate(&lndclient.GraphTopologyUpdate{
+		ChannelEdgeUpdates: []lndclient.ChannelEdgeUpdate{{
+			ChannelID: lnwire.NewShortChanIDFromInt(200),
+			RoutingPolicy: lndclient.RoutingPolicy{
+				FeeRateMilliMsat: 20,
+			},
+			AdvertisingNode: self,
+			ConnectingNode:  nodeA,
+		}},
+	})
+	require.EqualValues(
+		t, 20, monitor.edges[200].Node2Policy.FeeRateMilliMsat,
+	)
+
+	require.Equal(t, 1.0, testutil.ToFloat64(monitor.nodeUpdateCounter))
+	require.Equal(t, 3.0, testutil.ToFloat64(monitor.channelUpdateCounter))
+	require.Equal(t, 1.0, testutil.ToFloat64(monitor.channelCloseCounter))
+}
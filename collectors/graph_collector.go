@@ -4,8 +4,13 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"net"
+	"strings"
+	"time"
 
 	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/tor"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -27,14 +32,54 @@ const (
 	inboundFeeSignLabel         = "sign"
 	inboundFeeSignLabelPositive = "positive"
 	inboundFeeSignLabelNegative = "negative"
+
+	// Define the address types we categorize node addresses into.
+	addressTypeIPv4  = "ipv4"
+	addressTypeIPv6  = "ipv6"
+	addressTypeTorV3 = "torv3"
+	addressTypeDNS   = "dns"
 )
 
+// addressTypes is the set of address types we report node counts for.
+var addressTypes = []string{
+	addressTypeIPv4, addressTypeIPv6, addressTypeTorV3, addressTypeDNS,
+}
+
+// extraNodeFeatures holds the names of feature bits that nodes in the network
+// commonly advertise, but that lnd doesn't know about yet.
+var extraNodeFeatures = map[lnwire.FeatureBit]string{
+	38: "onion-messages",
+	39: "onion-messages",
+}
+
+// staleNodeAge is an age threshold for node announcements, along with the
+// label we report the number of nodes exceeding it under.
+type staleNodeAge struct {
+	label string
+	age   time.Duration
+}
+
+// staleNodeAges are the thresholds we count nodes with stale announcements
+// for. lnd prunes nodes that haven't been updated for two weeks, so nodes
+// older than that are only kept alive by their channels.
+var staleNodeAges = []staleNodeAge{
+	{label: "1d", age: 24 * time.Hour},
+	{label: "7d", age: 7 * 24 * time.Hour},
+	{label: "14d", age: 14 * 24 * time.Hour},
+	{label: "30d", age: 30 * 24 * time.Hour},
+}
+
 // GraphCollector is a collector that keeps track of graph information.
 type GraphCollector struct {
 	numEdgesDesc   *prometheus.Desc
 	numNodesDesc   *prometheus.Desc
 	numZombiesDesc *prometheus.Desc
 
+	nodeFeatureDesc      *prometheus.Desc
+	nodeAddressTypeDesc  *prometheus.Desc
+	staleNodesDesc       *prometheus.Desc
+	unannouncedNodesDesc *prometheus.Desc
+
 	avgOutDegreeDesc  *prometheus.Desc
 	maxOutDegreeDesc  *prometheus.Desc
 	graphDiameterDesc *prometheus.Desc
@@ -75,17 +120,38 @@ type GraphCollector struct {
 	minMaxHtlcMsatDesc    *prometheus.Desc
 	avgMaxHtlcMsatDesc    *prometheus.Desc
 
+	// The distributions below are exported as histograms and percentile
+	// gauges, depending on the graph stats configuration.
+	chanSizeDist      *graphDistribution
+	timelockDeltaDist *graphDistribution
+	minHtlcMsatDist   *graphDistribution
+	maxHtlcMsatDist   *graphDistribution
+	feeBaseMsatDist   *graphDistribution
+	feeRateMsatDist   *graphDistribution
+
 	lnd lndclient.LightningClient
 
+	// cfg specifies which metrics of graph distributions are exported.
+	cfg *GraphStatsConfig
+
+	// graph is the source we fetch the channel graph from. This is either
+	// lnd itself or the in-memory graph of the gossip monitor.
+	graph graphSource
+
 	// errChan is a channel that we send any errors that we encounter into.
 	// This channel should be buffered so that it does not block sends.
 	errChan chan<- error
 }
 
 // NewGraphCollector returns a new instance of the GraphCollector for the target
-// lnd client.
-func NewGraphCollector(lnd lndclient.LightningClient,
-	errChan chan<- error) *GraphCollector {
+// lnd client. The channel graph is fetched from the given graph source. If
+// cfg is nil, the default graph stats configuration is used.
+func NewGraphCollector(lnd lndclient.LightningClient, graph graphSource,
+	cfg *GraphStatsConfig, errChan chan<- error) *GraphCollector {
+
+	if cfg == nil {
+		cfg = DefaultGraphStatsConfig()
+	}
 
 	return &GraphCollector{
 		numEdgesDesc: prometheus.NewDesc(
@@ -104,6 +170,30 @@ func NewGraphCollector(lnd lndclient.LightningClient,
 			nil, nil,
 		),
 
+		nodeFeatureDesc: prometheus.NewDesc(
+			"lnd_graph_nodes_feature_count",
+			"number of nodes in the graph advertising a feature",
+			[]string{"feature"}, nil,
+		),
+		nodeAddressTypeDesc: prometheus.NewDesc(
+			"lnd_graph_nodes_address_type_count",
+			"number of nodes in the graph advertising an address "+
+				"of a type",
+			[]string{"address_type"}, nil,
+		),
+		staleNodesDesc: prometheus.NewDesc(
+			"lnd_graph_nodes_stale_count",
+			"number of nodes in the graph whose last announcement "+
+				"is older than an age",
+			[]string{"age"}, nil,
+		),
+		unannouncedNodesDesc: prometheus.NewDesc(
+			"lnd_graph_nodes_unannounced_count",
+			"number of nodes in the graph that we haven't "+
+				"received an announcement for",
+			nil, nil,
+		),
+
 		avgOutDegreeDesc: prometheus.NewDesc(
 			"lnd_graph_outdegree_avg",
 			"avg out degree of nodes in the network",
@@ -261,7 +351,46 @@ func NewGraphCollector(lnd lndclient.LightningClient,
 			nil, nil,
 		),
 
+		chanSizeDist: newGraphDistribution(
+			"lnd_graph_chan_size",
+			"channel sizes in the network in sat",
+			// 20k sat to ~6.5 BTC.
+			prometheus.ExponentialBuckets(20_000, 2, 16), cfg,
+		),
+		timelockDeltaDist: newGraphDistribution(
+			"lnd_graph_timelock_delta",
+			"time lock deltas for channel routing policies",
+			// 8 to 4096 blocks.
+			prometheus.ExponentialBuckets(8, 2, 10), cfg,
+		),
+		minHtlcMsatDist: newGraphDistribution(
+			"lnd_graph_min_htlc_msat",
+			"min htlcs for channel routing policies in msat",
+			// 1 msat to 1M sat.
+			prometheus.ExponentialBuckets(1, 10, 10), cfg,
+		),
+		maxHtlcMsatDist: newGraphDistribution(
+			"lnd_graph_max_htlc_msat",
+			"max htlcs for channel routing policies in msat",
+			// 1 sat to 100 BTC.
+			prometheus.ExponentialBuckets(1000, 10, 11), cfg,
+		),
+		feeBaseMsatDist: newGraphDistribution(
+			"lnd_graph_fee_base_msat",
+			"base fees for channel routing policies in msat",
+			// 1 to 1_048_576 msat.
+			prometheus.ExponentialBuckets(1, 2, 20), cfg,
+		),
+		feeRateMsatDist: newGraphDistribution(
+			"lnd_graph_fee_rate_msat",
+			"fee rates for channel routing policies in msat",
+			// 1 to 32768 PPM ~ 3 %.
+			prometheus.ExponentialBuckets(1, 2, 15), cfg,
+		),
+
 		lnd:     lnd,
+		cfg:     cfg,
+		graph:   graph,
 		errChan: errChan,
 	}
 }
@@ -276,12 +405,28 @@ func (g *GraphCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- g.numNodesDesc
 	ch <- g.numZombiesDesc
 
+	ch <- g.nodeFeatureDesc
+	ch <- g.nodeAddressTypeDesc
+	ch <- g.staleNodesDesc
+	ch <- g.unannouncedNodesDesc
+
 	ch <- g.avgOutDegreeDesc
 	ch <- g.maxOutDegreeDesc
 	ch <- g.graphDiameterDesc
 
 	ch <- g.networkCapacityDesc
 
+	ch <- g.inboundFeeBaseMsatDesc
+	ch <- g.inboundFeeRateMsatDesc
+
+	for _, dist := range g.distributions() {
+		dist.describe(ch, g.cfg)
+	}
+
+	if g.cfg.DisableSummaries {
+		return
+	}
+
 	ch <- g.avgChanSizeDesc
 	ch <- g.minChanSizeDesc
 	ch <- g.maxChanSizeDesc
@@ -307,20 +452,25 @@ func (g *GraphCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- g.avgFeeRateMsatDesc
 	ch <- g.medianFeeRateMsatDesc
 
-	ch <- g.inboundFeeBaseMsatDesc
-	ch <- g.inboundFeeRateMsatDesc
-
 	ch <- g.minMaxHtlcMsatDesc
 	ch <- g.maxMaxHtlcMsatDesc
 	ch <- g.avgMaxHtlcMsatDesc
 	ch <- g.medianMaxHtlcMsatDesc
 }
 
+// distributions returns all graph distributions of the collector.
+func (g *GraphCollector) distributions() []*graphDistribution {
+	return []*graphDistribution{
+		g.chanSizeDist, g.timelockDeltaDist, g.minHtlcMsatDist,
+		g.maxHtlcMsatDist, g.feeBaseMsatDist, g.feeRateMsatDist,
+	}
+}
+
 // Collect is called by the Prometheus registry when collecting metrics.
 //
 // NOTE: Part of the prometheus.Collector interface.
 func (g *GraphCollector) Collect(ch chan<- prometheus.Metric) {
-	resp, err := g.lnd.DescribeGraph(context.Background(), false)
+	resp, err := g.graph.DescribeGraph(context.Background(), false)
 	if err != nil {
 		g.errChan <- fmt.Errorf("GraphCollector DescribeGraph failed "+
 			"with: %v", err)
@@ -336,6 +486,7 @@ func (g *GraphCollector) Collect(ch chan<- prometheus.Metric) {
 		float64(len(resp.Nodes)),
 	)
 
+	g.collectNodeMetrics(ch, resp.Nodes)
 	g.collectRoutingPolicyMetrics(ch, resp.Edges)
 
 	networkInfo, err := g.lnd.NetworkInfo(context.Background())
@@ -369,6 +520,10 @@ func (g *GraphCollector) Collect(ch chan<- prometheus.Metric) {
 		float64(networkInfo.TotalNetworkCapacity),
 	)
 
+	if g.cfg.DisableSummaries {
+		return
+	}
+
 	ch <- prometheus.MustNewConstMetric(
 		g.avgChanSizeDesc, prometheus.GaugeValue,
 		float64(networkInfo.AvgChannelSize),
@@ -387,6 +542,152 @@ func (g *GraphCollector) Collect(ch chan<- prometheus.Metric) {
 	)
 }
 
+// collectNodeMetrics exports the distribution of feature bits, address types
+// and announcement ages across the nodes in the graph.
+func (g *GraphCollector) collectNodeMetrics(ch chan<- prometheus.Metric,
+	nodes []lndclient.Node) {
+
+	report := newNodeReport(nodes, time.Now())
+
+	for feature, count := range report.features {
+		ch <- prometheus.MustNewConstMetric(
+			g.nodeFeatureDesc, prometheus.GaugeValue,
+			float64(count), feature,
+		)
+	}
+
+	for _, addrType := range addressTypes {
+		ch <- prometheus.MustNewConstMetric(
+			g.nodeAddressTypeDesc, prometheus.GaugeValue,
+			float64(report.addressTypes[addrType]), addrType,
+		)
+	}
+
+	for _, staleAge := range staleNodeAges {
+		ch <- prometheus.MustNewConstMetric(
+			g.staleNodesDesc, prometheus.GaugeValue,
+			float64(report.stale[staleAge.label]), staleAge.label,
+		)
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		g.unannouncedNodesDesc, prometheus.GaugeValue,
+		float64(report.unannounced),
+	)
+}
+
+// nodeReport holds the node counts we export for the graph.
+type nodeReport struct {
+	// features maps the name of every known feature to the number of
+	// nodes advertising it.
+	features map[string]int
+
+	// addressTypes maps each address type to the number of nodes
+	// advertising at least one address of that type.
+	addressTypes map[string]int
+
+	// stale maps the label of each stale node age to the number of nodes
+	// whose last announcement is older than that.
+	stale map[string]int
+
+	// unannounced is the number of nodes without an announcement.
+	unannounced int
+}
+
+// newNodeReport compiles the node counts for the given set of nodes.
+func newNodeReport(nodes []lndclient.Node, now time.Time) *nodeReport {
+	report := &nodeReport{
+		features:     make(map[string]int),
+		addressTypes: make(map[string]int),
+		stale:        make(map[string]int),
+	}
+
+	// We report all features we know about, even if no node advertises
+	// them, so that their series don't disappear.
+	for _, name := range lnwire.Features {
+		report.features[name] = 0
+	}
+	for _, name := range extraNodeFeatures {
+		report.features[name] = 0
+	}
+
+	for _, node := range nodes {
+		// A node may set both the required and the optional bit of a
+		// feature, in which case we only count it once.
+		features := make(map[string]struct{})
+		for _, bit := range node.Features {
+			name, ok := lnwire.Features[bit]
+			if !ok {
+				name, ok = extraNodeFeatures[bit]
+			}
+			if ok {
+				features[name] = struct{}{}
+			}
+		}
+		for name := range features {
+			report.features[name]++
+		}
+
+		addrTypes := make(map[string]struct{})
+		for _, addr := range node.Addresses {
+			if addrType := nodeAddressType(addr); addrType != "" {
+				addrTypes[addrType] = struct{}{}
+			}
+		}
+		for addrType := range addrTypes {
+			report.addressTypes[addrType]++
+		}
+
+		// Nodes we haven't received an announcement for are part of
+		// the graph because of their channels, but don't have a last
+		// update time.
+		if node.LastUpdate.Unix() <= 0 {
+			report.unannounced++
+			continue
+		}
+
+		age := now.Sub(node.LastUpdate)
+		for _, staleAge := range staleNodeAges {
+			if age > staleAge.age {
+				report.stale[staleAge.label]++
+			}
+		}
+	}
+
+	return report
+}
+
+// nodeAddressType returns the type of the given node address, or an empty
+// string if the address doesn't match any of the types we track.
+func nodeAddressType(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.To4() != nil {
+			return addressTypeIPv4
+		}
+
+		return addressTypeIPv6
+	}
+
+	if strings.HasSuffix(host, tor.OnionSuffix) {
+		if len(host) == tor.V3Len {
+			return addressTypeTorV3
+		}
+
+		return ""
+	}
+
+	if host == "" {
+		return ""
+	}
+
+	return addressTypeDNS
+}
+
 func (g *GraphCollector) collectRoutingPolicyMetrics(
 	ch chan<- prometheus.Metric, edges []lndclient.ChannelEdge) {
 
@@ -395,6 +696,7 @@ func (g *GraphCollector) collectRoutingPolicyMetrics(
 	numEdges := uint32(len(edges)) * 2
 
 	var (
+		chanSizeStats = newStatsCompiler(uint32(len(edges)))
 		timelockStats = newStatsCompiler(numEdges)
 
 		minHTLCStats = newStatsCompiler(numEdges)
@@ -426,6 +728,8 @@ func (g *GraphCollector) collectRoutingPolicyMetrics(
 	)
 
 	for _, edge := range edges {
+		chanSizeStats.Observe(float64(edge.Capacity))
+
 		policies := []*lndclient.RoutingPolicy{
 			edge.Node1Policy, edge.Node2Policy,
 		}
@@ -475,12 +779,29 @@ func (g *GraphCollector) collectRoutingPolicyMetrics(
 		}
 	}
 
+	inboundFeeBaseStats.Collect(ch)
+	inboundFeeRateStats.Collect(ch)
+
+	// The channel size summaries are reported by lnd itself, so we only
+	// use our own samples for the distribution metrics.
+	chanSizeStats.Report()
 	timelockReport := timelockStats.Report()
 	minHTLCReport := minHTLCStats.Report()
 	maxHTLCReport := maxHTLCStats.Report()
 	feeBaseReport := feeBaseStats.Report()
 	feeRateReport := feeRateStats.Report()
 
+	g.chanSizeDist.collect(ch, g.cfg, &chanSizeStats)
+	g.timelockDeltaDist.collect(ch, g.cfg, &timelockStats)
+	g.minHtlcMsatDist.collect(ch, g.cfg, &minHTLCStats)
+	g.maxHtlcMsatDist.collect(ch, g.cfg, &maxHTLCStats)
+	g.feeBaseMsatDist.collect(ch, g.cfg, &feeBaseStats)
+	g.feeRateMsatDist.collect(ch, g.cfg, &feeRateStats)
+
+	if g.cfg.DisableSummaries {
+		return
+	}
+
 	ch <- prometheus.MustNewConstMetric(
 		g.minTimelockDeltaDesc, prometheus.GaugeValue,
 		timelockReport.min,
@@ -565,7 +886,4 @@ func (g *GraphCollector) collectRoutingPolicyMetrics(
 		g.medianFeeRateMsatDesc, prometheus.GaugeValue,
 		feeRateReport.median,
 	)
-
-	inboundFeeBaseStats.Collect(ch)
-	inboundFeeRateStats.Collect(ch)
 }
@@ -0,0 +1,71 @@
+package collectors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNodeAddressType tests the classification of node addresses.
+func TestNodeAddressType(t *testing.T) {
+	tests := map[string]string{
+		"1.2.3.4:9735":                addressTypeIPv4,
+		"[2001:db8::1]:9735":          addressTypeIPv6,
+		"example.com:9735":            addressTypeDNS,
+		"example.com":                 addressTypeDNS,
+		"abcdefghijklmnop.onion:9735": "",
+		"vww6ybal4bd7szmgncyruucpgfkqahzddi37ktceo3ah7ngmcopnpyyd." +
+			"onion:9735": addressTypeTorV3,
+	}
+
+	for addr, addrType := range tests {
+		require.Equal(t, addrType, nodeAddressType(addr), addr)
+	}
+}
+
+// TestNodeReport tests the node counts we compile for the graph.
+func TestNodeReport(t *testing.T) {
+	now := time.Unix(100*24*60*60, 0)
+
+	nodes := []lndclient.Node{
+		{
+			LastUpdate: now.Add(-time.Hour),
+			Features: []lnwire.FeatureBit{
+				lnwire.AnchorsZeroFeeHtlcTxRequired,
+				lnwire.AnchorsZeroFeeHtlcTxOptional,
+				lnwire.RouteBlindingOptional,
+				39,
+			},
+			Addresses: []string{"1.2.3.4:9735", "5.6.7.8:9735"},
+		},
+		{
+			LastUpdate: now.Add(-10 * 24 * time.Hour),
+			Features: []lnwire.FeatureBit{
+				lnwire.AnchorsZeroFeeHtlcTxOptional,
+			},
+			Addresses: []string{"1.2.3.4:9735", "[::1]:9735"},
+		},
+		{
+			LastUpdate: time.Unix(0, 0),
+		},
+	}
+
+	report := newNodeReport(nodes, now)
+
+	require.Equal(t, 2, report.features["anchors-zero-fee-htlc-tx"])
+	require.Equal(t, 1, report.features["route-blinding"])
+	require.Equal(t, 1, report.features["onion-messages"])
+	require.Equal(t, 0, report.features["simple-taproot-chans"])
+
+	require.Equal(t, 2, report.addressTypes[addressTypeIPv4])
+	require.Equal(t, 1, report.addressTypes[addressTypeIPv6])
+	require.Equal(t, 0, report.addressTypes[addressTypeTorV3])
+
+	require.Equal(t, 1, report.stale["1d"])
+	require.Equal(t, 1, report.stale["7d"])
+	require.Equal(t, 0, report.stale["14d"])
+	require.Equal(t, 1, report.unannounced)
+}
@@ -0,0 +1,151 @@
+package collectors
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// HistogramsNone disables histograms of graph distributions.
+	HistogramsNone = "none"
+
+	// HistogramsClassic exports graph distributions as classic histograms
+	// with fixed buckets.
+	HistogramsClassic = "classic"
+
+	// HistogramsNative exports graph distributions as native histograms,
+	// which are only available to scrapers using the protobuf format.
+	HistogramsNative = "native"
+
+	// nativeHistogramBucketFactor is the max growth factor between two
+	// buckets of a native histogram.
+	nativeHistogramBucketFactor = 1.1
+
+	// percentileLabel is the label used for percentile gauges.
+	percentileLabel = "percentile"
+)
+
+// GraphStatsConfig specifies how distributions of values across the channel
+// graph (routing policies and channel sizes) are exported.
+type GraphStatsConfig struct {
+	// DisableSummaries disables the min/max/avg/median gauges.
+	DisableSummaries bool `long:"disablesummaries" description:"Do not export the min/max/avg/median gauges of graph distributions"`
+
+	// Histograms is the type of histograms to export.
+	Histograms string `long:"histograms" description:"The type of histograms to export for graph distributions" choice:"none" choice:"classic" choice:"native"`
+
+	// Percentiles is the set of percentiles to export as gauges.
+	Percentiles []float64 `long:"percentile" description:"A percentile of graph distributions to export as a gauge (e.g. 10, 25, 75, 90, 99), can be specified multiple times"`
+}
+
+// DefaultGraphStatsConfig returns the default graph stats configuration,
+// which only exports the summary gauges.
+func DefaultGraphStatsConfig() *GraphStatsConfig {
+	return &GraphStatsConfig{
+		Histograms: HistogramsNone,
+	}
+}
+
+// Validate checks that the graph stats configuration is sane.
+func (c *GraphStatsConfig) Validate() error {
+	seen := make(map[float64]struct{}, len(c.Percentiles))
+	for _, percentile := range c.Percentiles {
+		if percentile < 0 || percentile > 100 {
+			return fmt.Errorf("graph percentile must be between 0 "+
+				"and 100, got %v", percentile)
+		}
+
+		if _, ok := seen[percentile]; ok {
+			return fmt.Errorf("duplicate graph percentile %v",
+				percentile)
+		}
+		seen[percentile] = struct{}{}
+	}
+
+	return nil
+}
+
+// histogramsEnabled returns true if histograms of graph distributions should
+// be exported.
+func (c *GraphStatsConfig) histogramsEnabled() bool {
+	return c.Histograms == HistogramsClassic ||
+		c.Histograms == HistogramsNative
+}
+
+// graphDistribution is a distribution of values across the channel graph that
+// can be exported as a histogram and as percentile gauges.
+type graphDistribution struct {
+	histogramOpts  prometheus.HistogramOpts
+	histogramDesc  *prometheus.Desc
+	percentileDesc *prometheus.Desc
+}
+
+// newGraphDistribution creates a graph distribution with the given metric
+// name prefix and description. The buckets are only used for classic
+// histograms.
+func newGraphDistribution(name, help string, buckets []float64,
+	cfg *GraphStatsConfig) *graphDistribution {
+
+	histogramOpts := prometheus.HistogramOpts{
+		Name: name + "_histogram",
+		Help: "histogram of " + help,
+	}
+	switch cfg.Histograms {
+	case HistogramsClassic:
+		histogramOpts.Buckets = buckets
+
+	case HistogramsNative:
+		histogramOpts.NativeHistogramBucketFactor =
+			nativeHistogramBucketFactor
+	}
+
+	return &graphDistribution{
+		histogramOpts: histogramOpts,
+		histogramDesc: prometheus.NewDesc(
+			histogramOpts.Name, histogramOpts.Help, nil, nil,
+		),
+		percentileDesc: prometheus.NewDesc(
+			name+"_percentile", "percentiles of "+help,
+			[]string{percentileLabel}, nil,
+		),
+	}
+}
+
+// describe sends the descriptors of all enabled metrics of the distribution
+// to the provided channel.
+func (d *graphDistribution) describe(ch chan<- *prometheus.Desc,
+	cfg *GraphStatsConfig) {
+
+	if cfg.histogramsEnabled() {
+		ch <- d.histogramDesc
+	}
+
+	if len(cfg.Percentiles) > 0 {
+		ch <- d.percentileDesc
+	}
+}
+
+// collect exports all enabled metrics of the distribution. Since percentiles
+// are read from the sorted samples, Report() must have been called on the
+// stats compiler beforehand.
+func (d *graphDistribution) collect(ch chan<- prometheus.Metric,
+	cfg *GraphStatsConfig, stats *statsCompiler) {
+
+	if cfg.histogramsEnabled() {
+		histogram := prometheus.NewHistogram(d.histogramOpts)
+		for _, sample := range stats.samples {
+			histogram.Observe(sample)
+		}
+		histogram.Collect(ch)
+	}
+
+	for _, percentile := range cfg.Percentiles {
+		ch <- prometheus.MustNewConstMetric(
+			d.percentileDesc, prometheus.GaugeValue,
+			stats.Percentile(percentile),
+			strconv.FormatFloat(percentile, 'f', -1, 64),
+		)
+	}
+}
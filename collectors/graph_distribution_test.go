@@ -0,0 +1,57 @@
+package collectors
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGraphDistribution tests that graph distributions export the configured
+// percentiles and histograms.
+func TestGraphDistribution(t *testing.T) {
+	stats := newStatsCompiler(5)
+	for _, value := range []float64{50, 10, 40, 20, 30} {
+		stats.Observe(value)
+	}
+	stats.Report()
+
+	require.Equal(t, 10.0, stats.Percentile(0))
+	require.Equal(t, 14.0, stats.Percentile(10))
+	require.Equal(t, 30.0, stats.Percentile(50))
+	require.Equal(t, 50.0, stats.Percentile(100))
+
+	collect := func(cfg *GraphStatsConfig) []prometheus.Metric {
+		dist := newGraphDistribution(
+			"test", "test values", []float64{10, 100}, cfg,
+		)
+
+		ch := make(chan prometheus.Metric, 10)
+		dist.collect(ch, cfg, &stats)
+		close(ch)
+
+		var metrics []prometheus.Metric
+		for metric := range ch {
+			metrics = append(metrics, metric)
+		}
+
+		return metrics
+	}
+
+	// By default, no distribution metrics are exported.
+	require.Empty(t, collect(DefaultGraphStatsConfig()))
+
+	// One gauge is exported per percentile, plus the histogram.
+	metrics := collect(&GraphStatsConfig{
+		Histograms:  HistogramsClassic,
+		Percentiles: []float64{25, 99.9},
+	})
+	require.Len(t, metrics, 3)
+
+	require.Error(t, (&GraphStatsConfig{
+		Percentiles: []float64{101},
+	}).Validate())
+	require.Error(t, (&GraphStatsConfig{
+		Percentiles: []float64{90, 90},
+	}).Validate())
+}
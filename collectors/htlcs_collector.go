@@ -113,6 +113,12 @@ func newHtlcMonitor(router lndclient.RouterClient,
 func (h *htlcMonitor) start() error {
 	htlcLogger.Info("Starting Htlc Monitor")
 
+	// We're restarted whenever lnd was unavailable, so we need a fresh
+	// quit channel each time. Htlcs that resolved in the meantime won't
+	// be reported, so we forget the ones that were in flight.
+	h.quit = make(chan struct{})
+	h.activeHtlcs = make(map[htlcswitch.HtlcKey]time.Time)
+
 	return h.consumeHtlcEvents()
 }
 
@@ -156,25 +162,29 @@ func (h *htlcMonitor) consumeHtlcEvents() error {
 			select {
 			case event, ok := <-htlcEvents:
 				if !ok {
-					h.errChan <- errors.New("htlc event " +
-						"stream termianted")
+					sendError(h.errChan, h.quit, errors.New(
+						"htlc event stream termianted",
+					))
 					return
 				}
 
 				err := h.processHtlcEvent(event)
 				if err != nil {
-					h.errChan <- err
+					sendError(h.errChan, h.quit, err)
 					return
 				}
 
 			case err, ok := <-htlcErrChan:
-				h.errChan <- fmt.Errorf("htlc stream "+
-					"exited: %v, closed: %v", err, ok)
+				sendError(h.errChan, h.quit, fmt.Errorf(
+					"htlc stream exited: %v, closed: %v",
+					err, ok,
+				))
 				return
 
+			// We don't report our shutdown as an error, since
+			// we're only shut down on purpose and nobody is
+			// listening for our errors anymore by then.
 			case <-h.quit:
-				h.errChan <- errors.New("htlc collector " +
-					"shutting down")
 				return
 			}
 		}
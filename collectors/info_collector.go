@@ -2,18 +2,82 @@ package collectors
 
 import (
 	"context"
-	"encoding/hex"
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/verrpc"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+const (
+	// chainBackendConfigKey is the key of lnd's chain backend in the
+	// configuration it returns in its debug info.
+	chainBackendConfigKey = "bitcoin.node"
+
+	// chainBackendUnknown is the chain backend we report if we can't
+	// learn it from lnd.
+	chainBackendUnknown = "unknown"
+
+	// chainBackendRetryInterval is how long we report an unknown chain
+	// backend before we try to fetch it again.
+	chainBackendRetryInterval = time.Hour
+
+	reachabilityClearnet = "clearnet"
+	reachabilityTor      = "tor"
+	reachabilityNone     = "none"
+)
+
+// uriReachability returns how our node can be reached given its advertised
+// uris: over clearnet if any of them is a clearnet address, over tor if all
+// of them are onion addresses, or not at all if we don't advertise any.
+func uriReachability(uris []string) string {
+	reachability := reachabilityNone
+	for _, uri := range uris {
+		// Uris are of the form pubkey@host:port.
+		addr := uri[strings.LastIndex(uri, "@")+1:]
+
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+
+		if !strings.HasSuffix(host, ".onion") {
+			return reachabilityClearnet
+		}
+		reachability = reachabilityTor
+	}
+
+	return reachability
+}
+
 // InfoCollector is a collector that keeps track of node information.
 type InfoCollector struct {
 	info *prometheus.Desc
 
-	lnd lndclient.LightningClient
+	nodeInfo     *prometheus.Desc
+	urisDesc     *prometheus.Desc
+	featureDesc  *prometheus.Desc
+	buildTagDesc *prometheus.Desc
+
+	lnd *lndclient.LndServices
+
+	// chainBackend is lnd's chain backend, or empty if we haven't learned
+	// it yet. Since lnd can't change its backend without restarting, and
+	// fetching it is expensive, we only fetch it until we succeed. It is
+	// guarded by chainBackendMtx.
+	chainBackend    string
+	chainBackendMtx sync.Mutex
+
+	// chainBackendRetry is the time before which we don't try to fetch
+	// lnd's chain backend again after failing to. It is guarded by
+	// chainBackendMtx.
+	chainBackendRetry time.Time
 
 	// errChan is a channel that we send any errors that we encounter into.
 	// This channel should be buffered so that it does not block sends.
@@ -22,7 +86,7 @@ type InfoCollector struct {
 
 // NewInfoCollector returns a new instance of the InfoCollector for the target
 // lnd client.
-func NewInfoCollector(lnd lndclient.LightningClient,
+func NewInfoCollector(lnd *lndclient.LndServices,
 	errChan chan<- error) *InfoCollector {
 
 	labels := []string{"version", "alias", "pubkey"}
@@ -30,6 +94,29 @@ func NewInfoCollector(lnd lndclient.LightningClient,
 		info: prometheus.NewDesc(
 			"lnd_info", "lnd node info", labels, nil,
 		),
+		nodeInfo: prometheus.NewDesc(
+			"lnd_node_info", "extended lnd node info",
+			[]string{
+				"pubkey", "version", "commit_hash",
+				"go_version", "network", "chain_backend",
+				"color", "reachability",
+			}, nil,
+		),
+		urisDesc: prometheus.NewDesc(
+			"lnd_node_uris_count",
+			"number of uris our node advertises",
+			nil, nil,
+		),
+		featureDesc: prometheus.NewDesc(
+			"lnd_node_feature_info",
+			"feature bit our node advertises",
+			[]string{"bit", "name", "required"}, nil,
+		),
+		buildTagDesc: prometheus.NewDesc(
+			"lnd_build_tag_info",
+			"build tag lnd was compiled with",
+			[]string{"tag"}, nil,
+		),
 		lnd:     lnd,
 		errChan: errChan,
 	}
@@ -42,21 +129,117 @@ func NewInfoCollector(lnd lndclient.LightningClient,
 // NOTE: Part of the prometheus.Collector interface.
 func (c *InfoCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.info
+	ch <- c.nodeInfo
+	ch <- c.urisDesc
+	ch <- c.featureDesc
+	ch <- c.buildTagDesc
 }
 
 // Collect is called by the Prometheus registry when collecting metrics.
 //
 // NOTE: Part of the prometheus.Collector interface.
 func (c *InfoCollector) Collect(ch chan<- prometheus.Metric) {
-	resp, err := c.lnd.GetInfo(context.Background())
+	// lndclient doesn't expose our feature bits, so we use the raw
+	// client.
+	rpcCtx, timeout, client := c.lnd.Client.RawClientWithMacAuth(
+		context.Background(),
+	)
+	rpcCtx, cancel := context.WithTimeout(rpcCtx, timeout)
+	defer cancel()
+
+	resp, err := client.GetInfo(rpcCtx, &lnrpc.GetInfoRequest{})
 	if err != nil {
 		c.errChan <- fmt.Errorf("InfoCollector GetInfo failed with: "+
 			"%v", err)
 		return
 	}
 
+	version, err := c.lnd.Versioner.GetVersion(context.Background())
+	if err != nil {
+		c.errChan <- fmt.Errorf("InfoCollector GetVersion failed "+
+			"with: %v", err)
+		return
+	}
+
+	c.collectInfo(ch, resp, version, c.getChainBackend())
+}
+
+// getChainBackend returns lnd's chain backend. lnd only exposes it as part of
+// its debug info, which isn't available on older versions of lnd, so we don't
+// fail if we can't fetch it. The debug info includes lnd's full config, which
+// makes it expensive to fetch, so after failing to fetch it we report an
+// unknown chain backend for a while rather than retrying on every scrape.
+func (c *InfoCollector) getChainBackend() string {
+	c.chainBackendMtx.Lock()
+	defer c.chainBackendMtx.Unlock()
+
+	if c.chainBackend != "" {
+		return c.chainBackend
+	}
+
+	if time.Now().Before(c.chainBackendRetry) {
+		return chainBackendUnknown
+	}
+
+	rpcCtx, timeout, client := c.lnd.Client.RawClientWithMacAuth(
+		context.Background(),
+	)
+	rpcCtx, cancel := context.WithTimeout(rpcCtx, timeout)
+	defer cancel()
+
+	resp, err := client.GetDebugInfo(rpcCtx, &lnrpc.GetDebugInfoRequest{})
+	if err != nil {
+		Logger.Debugf("Unable to fetch lnd's chain backend, retrying "+
+			"in %v: %v", chainBackendRetryInterval, err)
+
+		c.chainBackendRetry = time.Now().Add(chainBackendRetryInterval)
+
+		return chainBackendUnknown
+	}
+
+	c.chainBackend = resp.Config[chainBackendConfigKey]
+	if c.chainBackend == "" {
+		c.chainBackend = chainBackendUnknown
+	}
+
+	return c.chainBackend
+}
+
+// collectInfo exports the metrics of the given node info.
+func (c *InfoCollector) collectInfo(ch chan<- prometheus.Metric,
+	info *lnrpc.GetInfoResponse, version *verrpc.Version,
+	chainBackend string) {
+
+	ch <- prometheus.MustNewConstMetric(
+		c.info, prometheus.GaugeValue, 0, info.Version,
+		info.Alias, info.IdentityPubkey,
+	)
+
+	var network string
+	if len(info.Chains) > 0 {
+		network = info.Chains[0].Network
+	}
+
 	ch <- prometheus.MustNewConstMetric(
-		c.info, prometheus.GaugeValue, 0, resp.Version,
-		resp.Alias, hex.EncodeToString(resp.IdentityPubkey[:]),
+		c.nodeInfo, prometheus.GaugeValue, 1, info.IdentityPubkey,
+		version.Version, version.CommitHash, version.GoVersion,
+		network, chainBackend, info.Color, uriReachability(info.Uris),
 	)
+	ch <- prometheus.MustNewConstMetric(
+		c.urisDesc, prometheus.GaugeValue, float64(len(info.Uris)),
+	)
+
+	for bit, feature := range info.Features {
+		ch <- prometheus.MustNewConstMetric(
+			c.featureDesc, prometheus.GaugeValue, 1,
+			strconv.FormatUint(uint64(bit), 10), feature.Name,
+			strconv.FormatBool(feature.IsRequired),
+		)
+	}
+
+	for _, tag := range version.BuildTags {
+		ch <- prometheus.MustNewConstMetric(
+			c.buildTagDesc, prometheus.GaugeValue, 1, tag,
+		)
+	}
 }
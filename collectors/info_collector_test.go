@@ -0,0 +1,95 @@
+package collectors
+
+import (
+	"testing"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/verrpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUriReachability tests that we tell whether our node is reachable over
+// clearnet, only over tor or not at all.
+func TestUriReachability(t *testing.T) {
+	const (
+		clearnet = "02aa@203.0.113.1:9735"
+		ipv6     = "02aa@[2001:db8::1]:9735"
+		onion    = "02aa@abcdefghijklmnop.onion:9735"
+	)
+
+	require.Equal(t, reachabilityNone, uriReachability(nil))
+	require.Equal(t, reachabilityTor, uriReachability([]string{onion}))
+	require.Equal(
+		t, reachabilityClearnet, uriReachability([]string{ipv6}),
+	)
+	require.Equal(
+		t, reachabilityClearnet,
+		uriReachability([]string{onion, clearnet}),
+	)
+}
+
+// TestInfoCollector tests that we export our node's extended info, feature
+// bits and build tags.
+func TestInfoCollector(t *testing.T) {
+	collector := NewInfoCollector(nil, make(chan error, 1))
+
+	info := &lnrpc.GetInfoResponse{
+		Version:        "0.19.0-beta",
+		Alias:          "alice",
+		IdentityPubkey: "02aa",
+		Color:          "#3399ff",
+		Chains: []*lnrpc.Chain{{
+			Chain:   "bitcoin",
+			Network: "mainnet",
+		}},
+		Uris: []string{"02aa@abcdefghijklmnop.onion:9735"},
+		Features: map[uint32]*lnrpc.Feature{
+			0: {Name: "data-loss-protect", IsRequired: true},
+			9: {Name: "tlv-onion", IsKnown: true},
+		},
+	}
+	version := &verrpc.Version{
+		Version:    "0.19.0-beta",
+		CommitHash: "abc",
+		GoVersion:  "go1.23.6",
+		BuildTags:  []string{"autopilotrpc", "signrpc"},
+	}
+
+	ch := make(chan prometheus.Metric, 10)
+	collector.collectInfo(ch, info, version, "bitcoind")
+	close(ch)
+
+	series := collectMetrics(t, ch)
+	labels := func(desc *prometheus.Desc) []map[string]string {
+		var labels []map[string]string
+		for _, s := range series[desc] {
+			labels = append(labels, s.labels)
+		}
+
+		return labels
+	}
+
+	require.Equal(t, []map[string]string{{
+		"pubkey":        "02aa",
+		"version":       "0.19.0-beta",
+		"commit_hash":   "abc",
+		"go_version":    "go1.23.6",
+		"network":       "mainnet",
+		"chain_backend": "bitcoind",
+		"color":         "#3399ff",
+		"reachability":  reachabilityTor,
+	}}, labels(collector.nodeInfo))
+	require.Equal(t, []metricSeries{{
+		labels: map[string]string{},
+		value:  1,
+	}}, series[collector.urisDesc])
+
+	require.ElementsMatch(t, []map[string]string{
+		{"bit": "0", "name": "data-loss-protect", "required": "true"},
+		{"bit": "9", "name": "tlv-onion", "required": "false"},
+	}, labels(collector.featureDesc))
+	require.Equal(t, []map[string]string{
+		{"tag": "autopilotrpc"}, {"tag": "signrpc"},
+	}, labels(collector.buildTagDesc))
+}
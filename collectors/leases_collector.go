@@ -0,0 +1,109 @@
+package collectors
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// LeasesCollector is a collector that exports metrics about the outputs of
+// our wallet that are locked by leases, for example by PSBT funding flows,
+// sweeps or external tooling.
+type LeasesCollector struct {
+	lnd lndclient.WalletKitClient
+
+	leasesDesc      *prometheus.Desc
+	leaseValueDesc  *prometheus.Desc
+	leaseExpiryDesc *prometheus.Desc
+
+	// errChan is a channel that we send any errors that we encounter into.
+	// This channel should be buffered so that it does not block sends.
+	errChan chan<- error
+}
+
+// NewLeasesCollector returns a new instance of the LeasesCollector.
+func NewLeasesCollector(lnd lndclient.WalletKitClient,
+	errChan chan<- error) *LeasesCollector {
+
+	return &LeasesCollector{
+		lnd: lnd,
+		leasesDesc: prometheus.NewDesc(
+			"lnd_lease_count",
+			"number of leased outputs per lock id",
+			[]string{"lock_id"}, nil,
+		),
+		leaseValueDesc: prometheus.NewDesc(
+			"lnd_lease_value_sat",
+			"total value of leased outputs per lock id",
+			[]string{"lock_id"}, nil,
+		),
+		leaseExpiryDesc: prometheus.NewDesc(
+			"lnd_lease_expiry_seconds",
+			"time until the lease of an output expires",
+			[]string{"lock_id", "outpoint"}, nil,
+		),
+		errChan: errChan,
+	}
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once the
+// last descriptor has been sent.
+//
+// NOTE: Part of the prometheus.Collector interface.
+func (l *LeasesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- l.leasesDesc
+	ch <- l.leaseValueDesc
+	ch <- l.leaseExpiryDesc
+}
+
+// Collect is called by the Prometheus registry when collecting metrics.
+//
+// NOTE: Part of the prometheus.Collector interface.
+func (l *LeasesCollector) Collect(ch chan<- prometheus.Metric) {
+	leases, err := l.lnd.ListLeases(context.Background())
+	if err != nil {
+		l.errChan <- fmt.Errorf("LeasesCollector ListLeases failed "+
+			"with: %v", err)
+		return
+	}
+
+	l.collectLeases(ch, leases, time.Now())
+}
+
+// collectLeases exports the metrics of the given leases.
+func (l *LeasesCollector) collectLeases(ch chan<- prometheus.Metric,
+	leases []lndclient.LeaseDescriptor, now time.Time) {
+
+	var (
+		counts = make(map[string]int)
+		values = make(map[string]float64)
+	)
+	for _, lease := range leases {
+		lockID := hex.EncodeToString(lease.LockID[:])
+
+		counts[lockID]++
+		values[lockID] += float64(lease.Value)
+
+		ch <- prometheus.MustNewConstMetric(
+			l.leaseExpiryDesc, prometheus.GaugeValue,
+			lease.Expiration.Sub(now).Seconds(), lockID,
+			lease.Outpoint.String(),
+		)
+	}
+
+	for lockID, count := range counts {
+		ch <- prometheus.MustNewConstMetric(
+			l.leasesDesc, prometheus.GaugeValue, float64(count),
+			lockID,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			l.leaseValueDesc, prometheus.GaugeValue, values[lockID],
+			lockID,
+		)
+	}
+}
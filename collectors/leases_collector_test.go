@@ -0,0 +1,63 @@
+package collectors
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/lightninglabs/lndclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLeasesCollector tests that we group leased outputs by their lock id and
+// report the time until each of them expires.
+func TestLeasesCollector(t *testing.T) {
+	var (
+		collector = NewLeasesCollector(nil, make(chan error, 1))
+		now       = time.Unix(1_000_000, 0)
+		lockA     = wtxmgr.LockID{1}
+		lockB     = wtxmgr.LockID{2}
+	)
+
+	ch := make(chan prometheus.Metric, 10)
+	collector.collectLeases(ch, []lndclient.LeaseDescriptor{
+		{
+			LockID:     lockA,
+			Outpoint:   wire.OutPoint{Index: 0},
+			Value:      1000,
+			Expiration: now.Add(time.Minute),
+		},
+		{
+			LockID:     lockA,
+			Outpoint:   wire.OutPoint{Index: 1},
+			Value:      2000,
+			Expiration: now.Add(time.Hour),
+		},
+		{
+			LockID:     lockB,
+			Outpoint:   wire.OutPoint{Index: 2},
+			Value:      4000,
+			Expiration: now.Add(10 * time.Minute),
+		},
+	}, now)
+	close(ch)
+
+	series := collectMetrics(t, ch)
+
+	idA := hex.EncodeToString(lockA[:])
+	idB := hex.EncodeToString(lockB[:])
+
+	require.Equal(t, map[string]float64{idA: 2, idB: 1},
+		valuesByLabel(series[collector.leasesDesc], "lock_id"))
+	require.Equal(t, map[string]float64{idA: 3000, idB: 4000},
+		valuesByLabel(series[collector.leaseValueDesc], "lock_id"))
+	require.Len(t, series[collector.leaseExpiryDesc], 3)
+
+	expiries := valuesByLabel(
+		series[collector.leaseExpiryDesc], "outpoint",
+	)
+	require.Equal(t, 600.0, expiries[wire.OutPoint{Index: 2}.String()])
+}
@@ -9,18 +9,26 @@ import (
 	"github.com/lightningnetwork/lnd/build"
 )
 
+// The loggers below are disabled until initLogRotator is called, so that they
+// can safely be used before the log rotator is set up (e.g. in tests).
 var (
 	// Logger for lndmon's main process.
-	Logger btclog.Logger
+	Logger = btclog.Disabled
 
 	// htlcLogger is a logger for lndmon's htlc collector.
-	htlcLogger btclog.Logger
+	htlcLogger = btclog.Disabled
 
 	// paymentLogger is a logger for lndmon's payments monitor.
-	paymentLogger btclog.Logger
+	paymentLogger = btclog.Disabled
 
 	// watchtowerLogger is a logger for lndmon's watchtower client.
-	watchtowerLogger btclog.Logger
+	watchtowerLogger = btclog.Disabled
+
+	// policyLogger is a logger for lndmon's policy monitor.
+	policyLogger = btclog.Disabled
+
+	// gossipLogger is a logger for lndmon's gossip monitor.
+	gossipLogger = btclog.Disabled
 
 	noOpShutdownFunc = func() {}
 )
@@ -56,6 +64,8 @@ func initLogRotator(logFile string, maxLogFileSize, maxLogFiles int) error {
 	htlcLogger = logManager.GenSubLogger("HTLC", noOpShutdownFunc)
 	paymentLogger = logManager.GenSubLogger("PMNT", noOpShutdownFunc)
 	watchtowerLogger = logManager.GenSubLogger("WTCL", noOpShutdownFunc)
+	policyLogger = logManager.GenSubLogger("PLCY", noOpShutdownFunc)
+	gossipLogger = logManager.GenSubLogger("GSSP", noOpShutdownFunc)
 
 	// Set log level.
 	// TODO: consider making this configurable.
@@ -63,3 +73,12 @@ func initLogRotator(logFile string, maxLogFileSize, maxLogFiles int) error {
 
 	return nil
 }
+
+// InitLogging initializes lndmon's loggers with the given configuration. It
+// only needs to be called by commands that log without starting the exporter.
+func InitLogging(cfg *PrometheusConfig) error {
+	return initLogRotator(
+		filepath.Join(cfg.LogDir, defaultLogFilename),
+		defaultLogFileSize, defaultMaxLogFile,
+	)
+}
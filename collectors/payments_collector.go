@@ -76,6 +76,10 @@ func newPaymentsMonitor(lnd *lndclient.LndServices,
 func (p *paymentsMonitor) start() error {
 	paymentLogger.Info("Starting payments monitor...")
 
+	// We're restarted whenever lnd was unavailable, so we need a fresh
+	// quit channel each time.
+	p.quit = make(chan struct{})
+
 	// Attach macaroon authentication for the router service.
 	ctx, cancel := context.WithCancel(context.Background())
 	ctx, err := p.lnd.WithMacaroonAuthForService(
@@ -122,7 +126,7 @@ func (p *paymentsMonitor) start() error {
 					paymentLogger.Errorf("Error receiving "+
 						"payment update: %v", err)
 
-					p.errChan <- err
+					sendError(p.errChan, p.quit, err)
 					return
 				}
 				processPaymentUpdate(payment)
@@ -2,13 +2,40 @@ package collectors
 
 import (
 	"context"
-	"encoding/hex"
 	"fmt"
+	"net"
+	"strings"
+	"time"
 
+	"github.com/btcsuite/btcd/btcutil"
 	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/lightningnetwork/lnd/tor"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+const (
+	// Define the values of the labels describing a peer's connection.
+	peerDirectionInbound  = "inbound"
+	peerDirectionOutbound = "outbound"
+	peerNetworkClearnet   = "clearnet"
+	peerNetworkTor        = "tor"
+
+	// maxPeerErrorLength is the maximum length of a peer error that we
+	// export as a label. lnd only keeps the last few errors of each peer,
+	// but their messages may be arbitrarily long.
+	maxPeerErrorLength = 128
+)
+
+// peerSyncTypes maps lnd's gossip sync types to the labels we use for them.
+var peerSyncTypes = map[lnrpc.Peer_SyncType]string{
+	lnrpc.Peer_UNKNOWN_SYNC: "unknown",
+	lnrpc.Peer_ACTIVE_SYNC:  "active",
+	lnrpc.Peer_PASSIVE_SYNC: "passive",
+	lnrpc.Peer_PINNED_SYNC:  "pinned",
+}
+
 // PeerCollector is a collector that keeps track of peer information.
 type PeerCollector struct {
 	peerCountDesc *prometheus.Desc
@@ -21,16 +48,49 @@ type PeerCollector struct {
 	bytesSentDesc *prometheus.Desc
 	bytesRecvDesc *prometheus.Desc
 
+	// peerInfoDesc is an info-style metric that carries the peer's alias
+	// as a label, so that it can be joined on pubkey.
+	peerInfoDesc *prometheus.Desc
+
+	// connectionInfoDesc is an info-style metric that describes the
+	// connection to the peer.
+	connectionInfoDesc *prometheus.Desc
+
+	flapCountDesc  *prometheus.Desc
+	lastFlapDesc   *prometheus.Desc
+	errorCountDesc *prometheus.Desc
+	errorTimeDesc  *prometheus.Desc
+
+	// The descriptors below describe our channel peers' position in the
+	// public graph.
+	graphChannelsDesc      *prometheus.Desc
+	graphCapacityDesc      *prometheus.Desc
+	graphMedianFeeRateDesc *prometheus.Desc
+	graphLastUpdateAgeDesc *prometheus.Desc
+
 	lnd lndclient.LightningClient
 
+	// metricsVersion is the version of metric definitions we export.
+	metricsVersion int
+
+	// graph is the source we fetch the channel graph from. If nil, no
+	// graph metrics are exported for our peers.
+	graph graphSource
+
+	// aliases is used to resolve the aliases of our peers.
+	aliases *aliasCache
+
 	// errChan is a channel that we send any errors that we encounter into.
 	// This channel should be buffered so that it does not block sends.
 	errChan chan<- error
 }
 
 // NewPeerCollector returns a new instance of the PeerCollector for the target
-// lnd client.
-func NewPeerCollector(lnd lndclient.LightningClient,
+// lnd client. The aliases of our peers are resolved with the given alias
+// cache, and the graph metrics of our channel peers are computed from the
+// given graph source, which may be nil to disable them.
+func NewPeerCollector(lnd lndclient.LightningClient, aliases *aliasCache,
+	graph graphSource, metricsVersion int,
 	errChan chan<- error) *PeerCollector {
 
 	perPeerLabels := []string{"pubkey"}
@@ -61,8 +121,64 @@ func NewPeerCollector(lnd lndclient.LightningClient,
 			"bytes transmitted from this peer",
 			perPeerLabels, nil,
 		),
-		lnd:     lnd,
-		errChan: errChan,
+		peerInfoDesc: prometheus.NewDesc(
+			"lnd_peer_info",
+			"static peer metadata, always set to 1",
+			[]string{"pubkey", "alias"}, nil,
+		),
+		connectionInfoDesc: prometheus.NewDesc(
+			"lnd_peer_connection_info",
+			"peer connection metadata, always set to 1",
+			[]string{"pubkey", "direction", "network", "sync_type"},
+			nil,
+		),
+		flapCountDesc: prometheus.NewDesc(
+			"lnd_peer_flap_count",
+			"number of times lnd has seen this peer disconnect",
+			perPeerLabels, nil,
+		),
+		lastFlapDesc: prometheus.NewDesc(
+			"lnd_peer_last_flap_timestamp_seconds",
+			"timestamp of the last time this peer disconnected",
+			perPeerLabels, nil,
+		),
+		errorCountDesc: prometheus.NewDesc(
+			"lnd_peer_errors",
+			"number of recent errors lnd has stored for this peer",
+			perPeerLabels, nil,
+		),
+		errorTimeDesc: prometheus.NewDesc(
+			"lnd_peer_error_timestamp_seconds",
+			"timestamp of the last occurrence of a recent error "+
+				"of this peer",
+			[]string{"pubkey", "error"}, nil,
+		),
+		graphChannelsDesc: prometheus.NewDesc(
+			"lnd_peer_graph_channels",
+			"number of public channels of this peer",
+			perPeerLabels, nil,
+		),
+		graphCapacityDesc: prometheus.NewDesc(
+			"lnd_peer_graph_capacity_sat",
+			"total capacity of the public channels of this peer",
+			perPeerLabels, nil,
+		),
+		graphMedianFeeRateDesc: prometheus.NewDesc(
+			"lnd_peer_graph_fee_rate_ppm_median",
+			"median fee rate this peer charges on its public "+
+				"channels in ppm",
+			perPeerLabels, nil,
+		),
+		graphLastUpdateAgeDesc: prometheus.NewDesc(
+			"lnd_peer_graph_last_update_age_seconds",
+			"time since the last node announcement of this peer",
+			perPeerLabels, nil,
+		),
+		lnd:            lnd,
+		metricsVersion: metricsVersion,
+		graph:          graph,
+		aliases:        aliases,
+		errChan:        errChan,
 	}
 }
 
@@ -81,38 +197,79 @@ func (p *PeerCollector) Describe(ch chan<- *prometheus.Desc) {
 
 	ch <- p.bytesSentDesc
 	ch <- p.bytesRecvDesc
+
+	ch <- p.peerInfoDesc
+
+	ch <- p.connectionInfoDesc
+
+	ch <- p.flapCountDesc
+	ch <- p.lastFlapDesc
+	ch <- p.errorCountDesc
+	ch <- p.errorTimeDesc
+
+	if p.graph != nil {
+		ch <- p.graphChannelsDesc
+		ch <- p.graphCapacityDesc
+		ch <- p.graphMedianFeeRateDesc
+		ch <- p.graphLastUpdateAgeDesc
+	}
 }
 
 // Collect is called by the Prometheus registry when collecting metrics.
 //
 // NOTE: Part of the prometheus.Collector interface.
 func (p *PeerCollector) Collect(ch chan<- prometheus.Metric) {
-	listPeersResp, err := p.lnd.ListPeers(context.Background())
+	// We use the raw client, since lndclient doesn't expose the sync
+	// type, flaps and errors of our peers.
+	rpcCtx, timeout, client := p.lnd.RawClientWithMacAuth(
+		context.Background(),
+	)
+	rpcCtx, cancel := context.WithTimeout(rpcCtx, timeout)
+	defer cancel()
+
+	listPeersResp, err := client.ListPeers(
+		rpcCtx, &lnrpc.ListPeersRequest{},
+	)
 	if err != nil {
 		p.errChan <- fmt.Errorf("PeerCollector ListPeers failed with: "+
 			"%v", err)
 		return
 	}
 
+	// Version 1 exported the peer count and ping time as counters, and
+	// the ping time in nanoseconds despite the metric's name.
+	countType := prometheus.CounterValue
+	pingTimeScale := float64(time.Microsecond)
+	if p.metricsVersion >= MetricsVersion2 {
+		countType = prometheus.GaugeValue
+		pingTimeScale = 1
+	}
+
 	ch <- prometheus.MustNewConstMetric(
-		p.peerCountDesc, prometheus.CounterValue,
-		float64(len(listPeersResp)),
+		p.peerCountDesc, countType,
+		float64(len(listPeersResp.Peers)),
 	)
 
-	for _, peer := range listPeersResp {
-		pubkeyStr := hex.EncodeToString(peer.Pubkey[:])
+	for _, peer := range listPeersResp.Peers {
+		pubkey, err := route.NewVertexFromStr(peer.PubKey)
+		if err != nil {
+			p.errChan <- fmt.Errorf("PeerCollector invalid peer "+
+				"pubkey: %v", err)
+			return
+		}
+		pubkeyStr := peer.PubKey
 
 		ch <- prometheus.MustNewConstMetric(
-			p.pingTimeDesc, prometheus.CounterValue,
-			float64(peer.PingTime), pubkeyStr,
+			p.pingTimeDesc, countType,
+			float64(peer.PingTime)*pingTimeScale, pubkeyStr,
 		)
 		ch <- prometheus.MustNewConstMetric(
 			p.satSentDesc, prometheus.GaugeValue,
-			float64(peer.Sent), pubkeyStr,
+			float64(peer.SatSent), pubkeyStr,
 		)
 		ch <- prometheus.MustNewConstMetric(
 			p.satRecvDesc, prometheus.GaugeValue,
-			float64(peer.Received), pubkeyStr,
+			float64(peer.SatRecv), pubkeyStr,
 		)
 		ch <- prometheus.MustNewConstMetric(
 			p.bytesSentDesc, prometheus.GaugeValue,
@@ -120,7 +277,218 @@ func (p *PeerCollector) Collect(ch chan<- prometheus.Metric) {
 		)
 		ch <- prometheus.MustNewConstMetric(
 			p.bytesRecvDesc, prometheus.GaugeValue,
-			float64(peer.BytesReceived), pubkeyStr,
+			float64(peer.BytesRecv), pubkeyStr,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			p.peerInfoDesc, prometheus.GaugeValue, 1, pubkeyStr,
+			p.aliases.get(pubkey),
 		)
+
+		p.collectConnectionMetrics(ch, peer)
+	}
+
+	if p.graph != nil {
+		p.collectGraphMetrics(ch)
 	}
 }
+
+// collectConnectionMetrics exports the metrics describing our connection to
+// the given peer.
+func (p *PeerCollector) collectConnectionMetrics(ch chan<- prometheus.Metric,
+	peer *lnrpc.Peer) {
+
+	direction := peerDirectionOutbound
+	if peer.Inbound {
+		direction = peerDirectionInbound
+	}
+
+	syncType, ok := peerSyncTypes[peer.SyncType]
+	if !ok {
+		syncType = peerSyncTypes[lnrpc.Peer_UNKNOWN_SYNC]
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		p.connectionInfoDesc, prometheus.GaugeValue, 1, peer.PubKey,
+		direction, peerNetwork(peer.Address), syncType,
+	)
+
+	ch <- prometheus.MustNewConstMetric(
+		p.flapCountDesc, prometheus.GaugeValue,
+		float64(peer.FlapCount), peer.PubKey,
+	)
+	if peer.LastFlapNs > 0 {
+		ch <- prometheus.MustNewConstMetric(
+			p.lastFlapDesc, prometheus.GaugeValue,
+			float64(peer.LastFlapNs)/float64(time.Second),
+			peer.PubKey,
+		)
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		p.errorCountDesc, prometheus.GaugeValue,
+		float64(len(peer.Errors)), peer.PubKey,
+	)
+	for errStr, timestamp := range latestPeerErrors(peer.Errors) {
+		ch <- prometheus.MustNewConstMetric(
+			p.errorTimeDesc, prometheus.GaugeValue,
+			float64(timestamp), peer.PubKey, errStr,
+		)
+	}
+}
+
+// peerNetwork returns whether the given peer address is a clearnet or a Tor
+// address.
+func peerNetwork(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	if strings.HasSuffix(host, tor.OnionSuffix) {
+		return peerNetworkTor
+	}
+
+	return peerNetworkClearnet
+}
+
+// latestPeerErrors returns the latest timestamp of each of the given peer
+// errors, keyed by their (truncated) error message. Since lnd may store the
+// same error more than once, this makes sure we don't export duplicate series.
+func latestPeerErrors(peerErrors []*lnrpc.TimestampedError) map[string]uint64 {
+	latest := make(map[string]uint64, len(peerErrors))
+	for _, peerErr := range peerErrors {
+		errStr := peerErr.Error
+		if len(errStr) > maxPeerErrorLength {
+			errStr = errStr[:maxPeerErrorLength]
+		}
+
+		// Label values must be valid UTF-8, which neither the peer nor
+		// our truncation guarantees.
+		errStr = strings.ToValidUTF8(errStr, "")
+
+		timestamp, ok := latest[errStr]
+		if !ok || peerErr.Timestamp > timestamp {
+			latest[errStr] = peerErr.Timestamp
+		}
+	}
+
+	return latest
+}
+
+// collectGraphMetrics exports the graph metrics of all peers we have channels
+// with, regardless of whether they're currently connected.
+func (p *PeerCollector) collectGraphMetrics(ch chan<- prometheus.Metric) {
+	channels, err := p.lnd.ListChannels(context.Background(), false, false)
+	if err != nil {
+		p.errChan <- fmt.Errorf("PeerCollector ListChannels failed "+
+			"with: %v", err)
+		return
+	}
+
+	peers := make(map[route.Vertex]struct{}, len(channels))
+	for _, channel := range channels {
+		peers[channel.PubKeyBytes] = struct{}{}
+	}
+
+	graph, err := p.graph.DescribeGraph(context.Background(), false)
+	if err != nil {
+		p.errChan <- fmt.Errorf("PeerCollector DescribeGraph failed "+
+			"with: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for peer, stats := range newPeerGraphStats(peers, graph) {
+		pubkeyStr := peer.String()
+
+		ch <- prometheus.MustNewConstMetric(
+			p.graphChannelsDesc, prometheus.GaugeValue,
+			float64(stats.channels), pubkeyStr,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			p.graphCapacityDesc, prometheus.GaugeValue,
+			float64(stats.capacity), pubkeyStr,
+		)
+
+		// Peers that don't have any public channels or haven't sent a
+		// node announcement don't have a fee rate or last update.
+		if len(stats.feeRates.samples) > 0 {
+			report := stats.feeRates.Report()
+			ch <- prometheus.MustNewConstMetric(
+				p.graphMedianFeeRateDesc,
+				prometheus.GaugeValue, report.median,
+				pubkeyStr,
+			)
+		}
+
+		if stats.lastUpdate.Unix() > 0 {
+			ch <- prometheus.MustNewConstMetric(
+				p.graphLastUpdateAgeDesc,
+				prometheus.GaugeValue,
+				now.Sub(stats.lastUpdate).Seconds(), pubkeyStr,
+			)
+		}
+	}
+}
+
+// peerGraphStats holds the graph metrics of a single peer.
+type peerGraphStats struct {
+	// channels is the number of public channels of the peer.
+	channels int
+
+	// capacity is the total capacity of the peer's public channels.
+	capacity btcutil.Amount
+
+	// feeRates holds the fee rates of the policies the peer advertises.
+	feeRates statsCompiler
+
+	// lastUpdate is the time of the peer's last node announcement.
+	lastUpdate time.Time
+}
+
+// newPeerGraphStats compiles the graph metrics for the given set of peers.
+// Every peer is included in the result, even if it isn't part of the graph.
+func newPeerGraphStats(peers map[route.Vertex]struct{},
+	graph *lndclient.Graph) map[route.Vertex]*peerGraphStats {
+
+	stats := make(map[route.Vertex]*peerGraphStats, len(peers))
+	for peer := range peers {
+		stats[peer] = &peerGraphStats{
+			feeRates: newStatsCompiler(0),
+		}
+	}
+
+	for _, node := range graph.Nodes {
+		if peerStats, ok := stats[node.PubKey]; ok {
+			peerStats.lastUpdate = node.LastUpdate
+		}
+	}
+
+	for _, edge := range graph.Edges {
+		sides := []struct {
+			node   route.Vertex
+			policy *lndclient.RoutingPolicy
+		}{
+			{node: edge.Node1, policy: edge.Node1Policy},
+			{node: edge.Node2, policy: edge.Node2Policy},
+		}
+
+		for _, side := range sides {
+			peerStats, ok := stats[side.node]
+			if !ok {
+				continue
+			}
+
+			peerStats.channels++
+			peerStats.capacity += edge.Capacity
+
+			if side.policy != nil {
+				peerStats.feeRates.Observe(
+					float64(side.policy.FeeRateMilliMsat),
+				)
+			}
+		}
+	}
+
+	return stats
+}
@@ -0,0 +1,101 @@
+package collectors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPeerGraphStats tests that we compile the graph metrics of our peers
+// from the policies they advertise.
+func TestPeerGraphStats(t *testing.T) {
+	var (
+		peer        = route.Vertex{1}
+		privatePeer = route.Vertex{2}
+		other       = route.Vertex{3}
+		lastUpdate  = time.Unix(1000, 0)
+	)
+
+	graph := &lndclient.Graph{
+		Nodes: []lndclient.Node{
+			{PubKey: peer, LastUpdate: lastUpdate},
+			{PubKey: other},
+		},
+		Edges: []lndclient.ChannelEdge{
+			{
+				Capacity: 100,
+				Node1:    peer,
+				Node2:    other,
+				Node1Policy: &lndclient.RoutingPolicy{
+					FeeRateMilliMsat: 100,
+				},
+				Node2Policy: &lndclient.RoutingPolicy{
+					FeeRateMilliMsat: 5000,
+				},
+			},
+			{
+				Capacity: 200,
+				Node1:    other,
+				Node2:    peer,
+				Node2Policy: &lndclient.RoutingPolicy{
+					FeeRateMilliMsat: 300,
+				},
+			},
+			{
+				Capacity: 400,
+				Node1:    other,
+				Node2:    peer,
+			},
+		},
+	}
+
+	stats := newPeerGraphStats(map[route.Vertex]struct{}{
+		peer:        {},
+		privatePeer: {},
+	}, graph)
+	require.Len(t, stats, 2)
+
+	peerStats := stats[peer]
+	require.Equal(t, 3, peerStats.channels)
+	require.EqualValues(t, 700, peerStats.capacity)
+	require.Equal(t, lastUpdate, peerStats.lastUpdate)
+
+	// Only the fee rates the peer advertises itself are taken into
+	// account.
+	require.Equal(t, 200.0, peerStats.feeRates.Report().median)
+
+	require.Zero(t, stats[privatePeer].channels)
+	require.Empty(t, stats[privatePeer].feeRates.samples)
+}
+
+// TestLatestPeerErrors tests that we export a single, truncated series per
+// distinct peer error.
+func TestLatestPeerErrors(t *testing.T) {
+	longErr := string(make([]byte, maxPeerErrorLength+10))
+
+	latest := latestPeerErrors([]*lnrpc.TimestampedError{
+		{Timestamp: 100, Error: "unknown channel"},
+		{Timestamp: 300, Error: "unknown channel"},
+		{Timestamp: 200, Error: "unknown channel"},
+		{Timestamp: 0, Error: "invalid commit sig"},
+		{Timestamp: 400, Error: longErr},
+		{Timestamp: 500, Error: "bad \xff utf8"},
+	})
+
+	require.Equal(t, map[string]uint64{
+		"unknown channel":            300,
+		"invalid commit sig":         0,
+		longErr[:maxPeerErrorLength]: 400,
+		"bad  utf8":                  500,
+	}, latest)
+
+	require.Equal(t, peerNetworkTor, peerNetwork(
+		"vww6ybal4bd7szmgncyruucpgfkqahzddi37ktceo3ah7ngmcopnpyyd."+
+			"onion:9735",
+	))
+	require.Equal(t, peerNetworkClearnet, peerNetwork("1.2.3.4:9735"))
+}
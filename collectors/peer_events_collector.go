@@ -0,0 +1,441 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	peerStateLabel   = "state"
+	peerStateOnline  = "online"
+	peerStateOffline = "offline"
+)
+
+// uptimeWindow is a rolling window we compute peer uptime over, along with
+// the label we report it under.
+type uptimeWindow struct {
+	label  string
+	length time.Duration
+}
+
+// uptimeWindows are the rolling windows we report peer uptime for. Offline
+// peers are forgotten once they've been offline for the longest window.
+var uptimeWindows = []uptimeWindow{
+	{label: "1h", length: time.Hour},
+	{label: "24h", length: 24 * time.Hour},
+	{label: "7d", length: 7 * 24 * time.Hour},
+}
+
+// peerSession is a past period of time during which a peer was connected.
+type peerSession struct {
+	start, end time.Time
+}
+
+// peerConnection tracks the connection history of a single peer.
+type peerConnection struct {
+	// online is true if the peer is currently connected.
+	online bool
+
+	// since is the time at which the peer's current state began.
+	since time.Time
+
+	// sessions holds the peer's past sessions that overlap with the
+	// longest uptime window.
+	sessions []peerSession
+}
+
+// uptime returns the fraction of the window ending at now that the peer was
+// connected for. Since we don't know the peer's state before tracking began,
+// the window is truncated to start no earlier than that.
+func (p *peerConnection) uptime(trackingStart, now time.Time,
+	window time.Duration) float64 {
+
+	start := now.Add(-window)
+	if start.Before(trackingStart) {
+		start = trackingStart
+	}
+
+	total := now.Sub(start)
+	if total <= 0 {
+		return 0
+	}
+
+	overlap := func(sessionStart, sessionEnd time.Time) time.Duration {
+		if sessionStart.Before(start) {
+			sessionStart = start
+		}
+		if sessionEnd.Before(sessionStart) {
+			return 0
+		}
+
+		return sessionEnd.Sub(sessionStart)
+	}
+
+	var online time.Duration
+	for _, session := range p.sessions {
+		online += overlap(session.start, session.end)
+	}
+	if p.online {
+		online += overlap(p.since, now)
+	}
+
+	return float64(online) / float64(total)
+}
+
+// peerEventsMonitor subscribes to lnd's peer events to track how often our
+// peers connect and disconnect. Unlike the peer collector, which only sees a
+// snapshot of our peers at scrape time, this also catches peers that flap
+// between scrapes.
+type peerEventsMonitor struct {
+	lnd lndclient.LightningClient
+
+	// trackingStart is the time at which we started tracking our peers.
+	trackingStart time.Time
+
+	// peers holds the connection history of all peers we've seen within
+	// the longest uptime window. It is guarded by peersMtx.
+	peers    map[route.Vertex]*peerConnection
+	peersMtx sync.Mutex
+
+	// transitionCounter counts the times peers came online or went
+	// offline.
+	transitionCounter *prometheus.CounterVec
+
+	onlineDesc             *prometheus.Desc
+	uptimeDesc             *prometheus.Desc
+	connectionDurationDesc *prometheus.Desc
+
+	// quit is closed to signal that we need to shutdown.
+	quit chan struct{}
+
+	wg sync.WaitGroup
+
+	// errChan is a channel that we send any errors that we encounter into.
+	// This channel should be buffered so that it does not block sends.
+	errChan chan<- error
+}
+
+// A compile time check to ensure that peerEventsMonitor implements the
+// prometheus.Collector interface.
+var _ prometheus.Collector = (*peerEventsMonitor)(nil)
+
+// newPeerEventsMonitor creates a new peer events monitor.
+func newPeerEventsMonitor(lnd lndclient.LightningClient,
+	errChan chan error) *peerEventsMonitor {
+
+	return &peerEventsMonitor{
+		lnd:   lnd,
+		peers: make(map[route.Vertex]*peerConnection),
+		transitionCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "lnd",
+				Subsystem: "peer",
+				Name:      "transitions_total",
+				Help: "count of times this peer came online " +
+					"or went offline",
+			}, []string{"pubkey", peerStateLabel},
+		),
+		onlineDesc: prometheus.NewDesc(
+			"lnd_peer_online",
+			"whether this peer is currently connected",
+			[]string{"pubkey"}, nil,
+		),
+		uptimeDesc: prometheus.NewDesc(
+			"lnd_peer_uptime_ratio",
+			"fraction of time this peer was connected within a "+
+				"rolling window",
+			[]string{"pubkey", "window"}, nil,
+		),
+		connectionDurationDesc: prometheus.NewDesc(
+			"lnd_peer_connection_duration_seconds",
+			"time since this peer connected, 0 if it is offline",
+			[]string{"pubkey"}, nil,
+		),
+		quit:    make(chan struct{}),
+		errChan: errChan,
+	}
+}
+
+// start subscribes to peer events, records our currently connected peers and
+// begins the main event loop of the monitor.
+func (p *peerEventsMonitor) start() error {
+	Logger.Info("Starting peer events monitor")
+
+	// We're restarted whenever lnd was unavailable, so we need a fresh
+	// quit channel each time.
+	p.quit = make(chan struct{})
+
+	// Create a context to subscribe to events and cancel it on exit so
+	// that lnd can cancel the stream. We subscribe before listing our
+	// peers so that we don't miss any events in between.
+	ctx, cancel := context.WithCancel(context.Background())
+
+	rpcCtx, _, client := p.lnd.RawClientWithMacAuth(ctx)
+	stream, err := client.SubscribePeerEvents(
+		rpcCtx, &lnrpc.PeerEventSubscription{},
+	)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	peers, err := p.lnd.ListPeers(context.Background())
+	if err != nil {
+		cancel()
+		return fmt.Errorf("peer events monitor ListPeers failed "+
+			"with: %v", err)
+	}
+
+	p.syncPeers(peers, time.Now())
+
+	// The stream can only be read with blocking calls, so we read it in a
+	// separate goroutine and deliver its events to our main loop.
+	events := make(chan *lnrpc.PeerEvent)
+	streamErr := make(chan error, 1)
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		for {
+			event, err := stream.Recv()
+			if err != nil {
+				streamErr <- err
+				return
+			}
+
+			select {
+			case events <- event:
+			case <-p.quit:
+				return
+			}
+		}
+	}()
+
+	p.wg.Add(1)
+	go func() {
+		defer func() {
+			cancel()
+			p.wg.Done()
+		}()
+
+		ticker := time.NewTicker(cacheRefreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case event := <-events:
+				if err := p.processPeerEvent(
+					event, time.Now(),
+				); err != nil {
+					sendError(p.errChan, p.quit, err)
+					return
+				}
+
+			case err := <-streamErr:
+				sendError(p.errChan, p.quit, fmt.Errorf(
+					"peer event stream exited: %v", err,
+				))
+				return
+
+			case <-ticker.C:
+				p.prune(time.Now())
+
+			case <-p.quit:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// stop sends the peer events monitor's goroutines the instruction to shutdown
+// and waits for them to exit.
+func (p *peerEventsMonitor) stop() {
+	Logger.Info("Stopping peer events monitor")
+
+	close(p.quit)
+	p.wg.Wait()
+}
+
+// collectors returns all of the collectors that the peer events monitor uses.
+// Since uptime and connection duration depend on the time of the scrape, the
+// monitor itself collects those.
+func (p *peerEventsMonitor) collectors() []prometheus.Collector {
+	return []prometheus.Collector{p.transitionCounter, p}
+}
+
+// processPeerEvent records a peer coming online or going offline.
+func (p *peerEventsMonitor) processPeerEvent(event *lnrpc.PeerEvent,
+	now time.Time) error {
+
+	pubkey, err := route.NewVertexFromStr(event.PubKey)
+	if err != nil {
+		return fmt.Errorf("invalid peer event pubkey: %v", err)
+	}
+
+	online := event.Type == lnrpc.PeerEvent_PEER_ONLINE
+
+	p.peersMtx.Lock()
+	defer p.peersMtx.Unlock()
+
+	p.setOnline(pubkey, online, now)
+
+	return nil
+}
+
+// syncPeers records the peers that lnd is currently connected to. The first
+// time we're started, this is where we start tracking our peers. When we're
+// restarted after lnd was unavailable, we record the transitions we missed in
+// the meantime as happening now.
+func (p *peerEventsMonitor) syncPeers(peers []lndclient.Peer, now time.Time) {
+	p.peersMtx.Lock()
+	defer p.peersMtx.Unlock()
+
+	if p.trackingStart.IsZero() {
+		p.trackingStart = now
+		for _, peer := range peers {
+			p.peers[peer.Pubkey] = &peerConnection{
+				online: true,
+				since:  now,
+			}
+		}
+
+		return
+	}
+
+	connected := make(map[route.Vertex]struct{}, len(peers))
+	for _, peer := range peers {
+		connected[peer.Pubkey] = struct{}{}
+		p.setOnline(peer.Pubkey, true, now)
+	}
+
+	for pubkey := range p.peers {
+		if _, ok := connected[pubkey]; !ok {
+			p.setOnline(pubkey, false, now)
+		}
+	}
+}
+
+// setOnline records whether a peer is online at the given time. The caller
+// must hold peersMtx.
+func (p *peerEventsMonitor) setOnline(pubkey route.Vertex, online bool,
+	now time.Time) {
+
+	// Peers we haven't seen yet were offline since we started tracking.
+	peer, ok := p.peers[pubkey]
+	if !ok {
+		peer = &peerConnection{
+			since: p.trackingStart,
+		}
+		p.peers[pubkey] = peer
+	}
+
+	// lnd may notify us of the same state more than once, which we don't
+	// count as a transition.
+	if peer.online == online {
+		return
+	}
+
+	if peer.online {
+		peer.sessions = append(peer.sessions, peerSession{
+			start: peer.since,
+			end:   now,
+		})
+	}
+	peer.online = online
+	peer.since = now
+
+	state := peerStateOffline
+	if online {
+		state = peerStateOnline
+	}
+	p.transitionCounter.WithLabelValues(pubkey.String(), state).Inc()
+
+	Logger.Debugf("Peer %v is now %v", pubkey, state)
+}
+
+// prune removes sessions that lie outside of our longest uptime window and
+// forgets peers that have been offline for longer than that.
+func (p *peerEventsMonitor) prune(now time.Time) {
+	cutoff := now.Add(-uptimeWindows[len(uptimeWindows)-1].length)
+
+	p.peersMtx.Lock()
+	defer p.peersMtx.Unlock()
+
+	for pubkey, peer := range p.peers {
+		if !peer.online && peer.since.Before(cutoff) {
+			delete(p.peers, pubkey)
+			p.transitionCounter.DeletePartialMatch(
+				prometheus.Labels{"pubkey": pubkey.String()},
+			)
+
+			continue
+		}
+
+		var sessions []peerSession
+		for _, session := range peer.sessions {
+			if session.end.After(cutoff) {
+				sessions = append(sessions, session)
+			}
+		}
+		peer.sessions = sessions
+	}
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once the
+// last descriptor has been sent.
+//
+// NOTE: Part of the prometheus.Collector interface.
+func (p *peerEventsMonitor) Describe(ch chan<- *prometheus.Desc) {
+	ch <- p.onlineDesc
+	ch <- p.uptimeDesc
+	ch <- p.connectionDurationDesc
+}
+
+// Collect is called by the Prometheus registry when collecting metrics.
+//
+// NOTE: Part of the prometheus.Collector interface.
+func (p *peerEventsMonitor) Collect(ch chan<- prometheus.Metric) {
+	now := time.Now()
+
+	p.peersMtx.Lock()
+	defer p.peersMtx.Unlock()
+
+	for pubkey, peer := range p.peers {
+		pubkeyStr := pubkey.String()
+
+		var online, duration float64
+		if peer.online {
+			online = 1
+			duration = now.Sub(peer.since).Seconds()
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			p.onlineDesc, prometheus.GaugeValue, online, pubkeyStr,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			p.connectionDurationDesc, prometheus.GaugeValue,
+			duration, pubkeyStr,
+		)
+
+		for _, window := range uptimeWindows {
+			ch <- prometheus.MustNewConstMetric(
+				p.uptimeDesc, prometheus.GaugeValue,
+				peer.uptime(
+					p.trackingStart, now, window.length,
+				),
+				pubkeyStr, window.label,
+			)
+		}
+	}
+}
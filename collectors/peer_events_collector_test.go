@@ -0,0 +1,111 @@
+package collectors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPeerEventsMonitor tests that peer events are counted and used to
+// compute the uptime of our peers.
+func TestPeerEventsMonitor(t *testing.T) {
+	var (
+		peer  = route.Vertex{1}
+		start = time.Unix(1_000_000, 0)
+	)
+
+	monitor := newPeerEventsMonitor(nil, make(chan error, 1))
+	monitor.trackingStart = start
+
+	event := func(eventType lnrpc.PeerEvent_EventType,
+		offset time.Duration) {
+
+		err := monitor.processPeerEvent(&lnrpc.PeerEvent{
+			PubKey: peer.String(),
+			Type:   eventType,
+		}, start.Add(offset))
+		require.NoError(t, err)
+	}
+
+	// The peer comes online 10 minutes after we started tracking, goes
+	// offline after 20 minutes and comes back 10 minutes later. The
+	// duplicate online event must not be counted.
+	event(lnrpc.PeerEvent_PEER_ONLINE, 10*time.Minute)
+	event(lnrpc.PeerEvent_PEER_ONLINE, 15*time.Minute)
+	event(lnrpc.PeerEvent_PEER_OFFLINE, 30*time.Minute)
+	event(lnrpc.PeerEvent_PEER_ONLINE, 40*time.Minute)
+
+	require.Equal(t, 2.0, testutil.ToFloat64(
+		monitor.transitionCounter.WithLabelValues(
+			peer.String(), peerStateOnline,
+		),
+	))
+	require.Equal(t, 1.0, testutil.ToFloat64(
+		monitor.transitionCounter.WithLabelValues(
+			peer.String(), peerStateOffline,
+		),
+	))
+
+	// After one hour, the peer was online for 40 out of 60 minutes. Over
+	// the last 30 minutes, it was online for 20 minutes.
+	now := start.Add(time.Hour)
+	conn := monitor.peers[peer]
+	require.InDelta(t, 2.0/3.0, conn.uptime(start, now, time.Hour), 1e-9)
+	require.InDelta(
+		t, 2.0/3.0, conn.uptime(start, now, 24*time.Hour), 1e-9,
+	)
+	require.InDelta(
+		t, 2.0/3.0, conn.uptime(start, now, 30*time.Minute), 1e-9,
+	)
+
+	// Once the peer has been offline for longer than our longest window,
+	// it is forgotten.
+	event(lnrpc.PeerEvent_PEER_OFFLINE, 2*time.Hour)
+	monitor.prune(start.Add(8 * 24 * time.Hour))
+	require.Empty(t, monitor.peers)
+	require.Zero(t, testutil.CollectAndCount(monitor.transitionCounter))
+}
+
+// TestPeerEventsMonitorSync tests that the peers we missed connecting or
+// disconnecting while lnd was unavailable transition when we resync.
+func TestPeerEventsMonitorSync(t *testing.T) {
+	var (
+		peerA = route.Vertex{1}
+		peerB = route.Vertex{2}
+		start = time.Unix(1_000_000, 0)
+	)
+
+	monitor := newPeerEventsMonitor(nil, make(chan error, 1))
+
+	transitions := func(peer route.Vertex, state string) float64 {
+		return testutil.ToFloat64(
+			monitor.transitionCounter.WithLabelValues(
+				peer.String(), state,
+			),
+		)
+	}
+
+	// The peers we're connected to when we start tracking are online
+	// without having transitioned.
+	monitor.syncPeers([]lndclient.Peer{{Pubkey: peerA}}, start)
+	require.Equal(t, start, monitor.trackingStart)
+	require.True(t, monitor.peers[peerA].online)
+	require.Zero(t, transitions(peerA, peerStateOnline))
+
+	// While lnd was unavailable, peer A disconnected and peer B connected.
+	now := start.Add(time.Hour)
+	monitor.syncPeers([]lndclient.Peer{{Pubkey: peerB}}, now)
+	require.Equal(t, start, monitor.trackingStart)
+
+	require.False(t, monitor.peers[peerA].online)
+	require.Equal(t, 1.0, transitions(peerA, peerStateOffline))
+
+	require.True(t, monitor.peers[peerB].online)
+	require.Equal(t, now, monitor.peers[peerB].since)
+	require.Equal(t, 1.0, transitions(peerB, peerStateOnline))
+}
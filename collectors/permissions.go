@@ -0,0 +1,456 @@
+package collectors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/lightninglabs/lndclient"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// PermissionsOnMissingFail fails lndmon's startup if its macaroon
+	// lacks permissions that an enabled collector needs.
+	PermissionsOnMissingFail = "fail"
+
+	// PermissionsOnMissingDisable disables the collectors whose
+	// permissions lndmon's macaroon lacks.
+	PermissionsOnMissingDisable = "disable"
+)
+
+// The names of the collectors whose permissions we check.
+const (
+	collectorChain         = "chain"
+	collectorChannels      = "channels"
+	collectorWallet        = "wallet"
+	collectorAnchorReserve = "anchorreserve"
+	collectorLeases        = "leases"
+	collectorSweeps        = "sweeps"
+	collectorPeers         = "peers"
+	collectorInfo          = "info"
+	collectorWtClient      = "wtclient"
+	collectorWtServer      = "wtserver"
+	collectorTransactions  = "transactions"
+	collectorWalletState   = "walletstate"
+	collectorFeeEstimates  = "feeestimates"
+	collectorBlocks        = "blocks"
+	collectorHtlcs         = "htlcs"
+	collectorPayments      = "payments"
+	collectorGraph         = "graph"
+	collectorGossip        = "gossip"
+	collectorCentrality    = "centrality"
+	collectorPolicies      = "policies"
+	collectorPeerEvents    = "peerevents"
+)
+
+// ErrPermissionCheckUnsupported is returned if lnd doesn't support checking
+// the permissions of a macaroon.
+var ErrPermissionCheckUnsupported = errors.New("lnd doesn't support " +
+	"checking macaroon permissions")
+
+// PermissionsConfig is the set of configuration data that specifies how the
+// permissions of lndmon's macaroon are checked at startup.
+type PermissionsConfig struct {
+	// SkipCheck disables checking the permissions of our macaroon.
+	SkipCheck bool `long:"skipcheck" description:"Do not check whether lndmon's macaroon has the permissions its collectors need at startup"`
+
+	// OnMissing is what we do if our macaroon lacks permissions that an
+	// enabled collector needs.
+	OnMissing string `long:"onmissing" description:"What to do if lndmon's macaroon lacks permissions that a collector needs: fail at startup, or disable the collector" choice:"fail" choice:"disable"`
+}
+
+// DefaultPermissionsConfig returns the default permissions configuration.
+func DefaultPermissionsConfig() *PermissionsConfig {
+	return &PermissionsConfig{
+		OnMissing: PermissionsOnMissingFail,
+	}
+}
+
+// collectorRPCs describes the RPCs that a collector uses.
+type collectorRPCs struct {
+	// name is the name we report the collector under.
+	name string
+
+	// methods are the full gRPC method names of the RPCs the collector
+	// uses.
+	methods []string
+
+	// optionalMethods are the full gRPC method names of RPCs the
+	// collector uses if our macaroon permits it, but can do without.
+	optionalMethods []string
+
+	// enabled returns true if the collector is enabled in the given
+	// config.
+	enabled func(cfg *MonitoringConfig) bool
+
+	// disable disables the collector in the given config.
+	disable func(cfg *MonitoringConfig)
+}
+
+// baseCollectorRPCs describes the RPCs of a collector that is enabled unless
+// it was disabled because our macaroon lacks its permissions.
+func baseCollectorRPCs(name string, methods ...string) collectorRPCs {
+	return collectorRPCs{
+		name:    name,
+		methods: methods,
+		enabled: func(cfg *MonitoringConfig) bool {
+			return !cfg.DisabledCollectors[name]
+		},
+		disable: func(cfg *MonitoringConfig) {
+			if cfg.DisabledCollectors == nil {
+				cfg.DisabledCollectors = make(map[string]bool)
+			}
+			cfg.DisabledCollectors[name] = true
+		},
+	}
+}
+
+// withOptionalMethods returns a copy of the collector's description with the
+// given optional methods.
+func (c collectorRPCs) withOptionalMethods(methods ...string) collectorRPCs {
+	c.optionalMethods = methods
+	return c
+}
+
+var (
+	// startupMethods are the full gRPC method names of the RPCs lndmon
+	// uses to connect to lnd, regardless of which collectors are enabled.
+	startupMethods = []string{
+		"/lnrpc.Lightning/GetInfo",
+		"/verrpc.Versioner/GetVersion",
+	}
+
+	// permissionCheckMethods are the full gRPC method names of the RPCs
+	// lndmon uses to check the permissions of its macaroon at startup.
+	permissionCheckMethods = []string{
+		"/lnrpc.Lightning/ListPermissions",
+		"/lnrpc.Lightning/CheckMacaroonPermissions",
+	}
+)
+
+// allCollectorRPCs describes the RPCs that each of our collectors uses.
+var allCollectorRPCs = []collectorRPCs{
+	baseCollectorRPCs(
+		collectorChain, "/lnrpc.Lightning/GetInfo",
+	),
+	baseCollectorRPCs(
+		collectorChannels, "/lnrpc.Lightning/GetInfo",
+		"/lnrpc.Lightning/ListChannels",
+		"/lnrpc.Lightning/PendingChannels",
+		"/lnrpc.Lightning/ClosedChannels",
+		"/lnrpc.Lightning/ChannelBalance",
+		"/lnrpc.Lightning/GetNodeInfo",
+	),
+	baseCollectorRPCs(
+		collectorWallet, "/lnrpc.Lightning/WalletBalance",
+		"/walletrpc.WalletKit/ListUnspent",
+		"/walletrpc.WalletKit/ListAccounts",
+	),
+	baseCollectorRPCs(
+		collectorAnchorReserve, "/lnrpc.Lightning/WalletBalance",
+		"/walletrpc.WalletKit/RequiredReserve",
+	),
+	baseCollectorRPCs(
+		collectorLeases, "/walletrpc.WalletKit/ListLeases",
+	),
+	baseCollectorRPCs(
+		collectorSweeps, "/lnrpc.Lightning/GetInfo",
+		"/walletrpc.WalletKit/PendingSweeps",
+	),
+	baseCollectorRPCs(
+		collectorPeers, "/lnrpc.Lightning/ListPeers",
+		"/lnrpc.Lightning/ListChannels",
+		"/lnrpc.Lightning/GetNodeInfo",
+	),
+	baseCollectorRPCs(
+		collectorInfo, "/lnrpc.Lightning/GetInfo",
+		"/verrpc.Versioner/GetVersion",
+	).withOptionalMethods("/lnrpc.Lightning/GetDebugInfo"),
+	baseCollectorRPCs(
+		collectorWtClient, "/wtclientrpc.WatchtowerClient/ListTowers",
+		"/wtclientrpc.WatchtowerClient/Policy",
+		"/wtclientrpc.WatchtowerClient/Stats",
+	),
+	baseCollectorRPCs(
+		collectorWtServer, "/watchtowerrpc.Watchtower/GetInfo",
+	),
+	baseCollectorRPCs(
+		collectorTransactions, "/lnrpc.Lightning/GetInfo",
+		"/lnrpc.Lightning/GetTransactions",
+	),
+	baseCollectorRPCs(
+		collectorWalletState, "/lnrpc.State/SubscribeState",
+	),
+	{
+		name: collectorFeeEstimates,
+		methods: []string{
+			"/walletrpc.WalletKit/EstimateFee",
+			"/lnrpc.Lightning/ListChannels",
+		},
+		enabled: func(cfg *MonitoringConfig) bool {
+			return cfg.FeeEstimates != nil &&
+				!cfg.FeeEstimates.Disable
+		},
+		disable: func(cfg *MonitoringConfig) {
+			cfg.FeeEstimates.Disable = true
+		},
+	},
+	{
+		name: collectorBlocks,
+		methods: []string{
+			"/chainrpc.ChainNotifier/RegisterBlockEpochNtfn",
+			"/chainrpc.ChainKit/GetBlockHeader",
+		},
+		enabled: func(cfg *MonitoringConfig) bool {
+			return cfg.Blocks != nil && !cfg.Blocks.Disable
+		},
+		disable: func(cfg *MonitoringConfig) {
+			cfg.Blocks.Disable = true
+		},
+	},
+	{
+		name:    collectorHtlcs,
+		methods: []string{"/routerrpc.Router/SubscribeHtlcEvents"},
+		enabled: func(cfg *MonitoringConfig) bool {
+			return !cfg.DisableHtlc
+		},
+		disable: func(cfg *MonitoringConfig) {
+			cfg.DisableHtlc = true
+		},
+	},
+	{
+		name:    collectorPayments,
+		methods: []string{"/routerrpc.Router/TrackPayments"},
+		enabled: func(cfg *MonitoringConfig) bool {
+			return !cfg.DisablePayments
+		},
+		disable: func(cfg *MonitoringConfig) {
+			cfg.DisablePayments = true
+		},
+	},
+	{
+		name: collectorGraph,
+		methods: []string{
+			"/lnrpc.Lightning/DescribeGraph",
+			"/lnrpc.Lightning/GetNetworkInfo",
+		},
+		enabled: func(cfg *MonitoringConfig) bool {
+			return !cfg.DisableGraph
+		},
+		disable: func(cfg *MonitoringConfig) {
+			cfg.DisableGraph = true
+		},
+	},
+	{
+		name: collectorGossip,
+		methods: []string{
+			"/lnrpc.Lightning/SubscribeChannelGraph",
+			"/lnrpc.Lightning/DescribeGraph",
+		},
+		enabled: func(cfg *MonitoringConfig) bool {
+			return !cfg.DisableGraph && !cfg.DisableGossip
+		},
+		disable: func(cfg *MonitoringConfig) {
+			cfg.DisableGossip = true
+		},
+	},
+	{
+		name:    collectorCentrality,
+		methods: []string{"/lnrpc.Lightning/DescribeGraph"},
+		enabled: func(cfg *MonitoringConfig) bool {
+			return !cfg.DisableGraph && cfg.Centrality != nil &&
+				!cfg.Centrality.Disable
+		},
+		disable: func(cfg *MonitoringConfig) {
+			cfg.Centrality.Disable = true
+		},
+	},
+	{
+		name: collectorPolicies,
+		methods: []string{
+			"/lnrpc.Lightning/SubscribeChannelGraph",
+			"/lnrpc.Lightning/ListChannels",
+			"/lnrpc.Lightning/GetNodeInfo",
+		},
+		enabled: func(cfg *MonitoringConfig) bool {
+			return !cfg.DisablePolicyUpdates
+		},
+		disable: func(cfg *MonitoringConfig) {
+			cfg.DisablePolicyUpdates = true
+		},
+	},
+	{
+		name: collectorPeerEvents,
+		methods: []string{
+			"/lnrpc.Lightning/SubscribePeerEvents",
+			"/lnrpc.Lightning/ListPeers",
+		},
+		enabled: func(cfg *MonitoringConfig) bool {
+			return !cfg.DisablePeerEvents
+		},
+		disable: func(cfg *MonitoringConfig) {
+			cfg.DisablePeerEvents = true
+		},
+	},
+}
+
+// CollectorPermissions is the result of checking whether a macaroon has the
+// permissions that a collector needs.
+type CollectorPermissions struct {
+	// Name is the name of the collector.
+	Name string
+
+	// Missing holds the RPCs of the collector that the macaroon lacks
+	// permissions for.
+	Missing []string
+
+	// disable disables the collector.
+	disable func(cfg *MonitoringConfig)
+}
+
+// Disable disables the collector in the given config.
+func (c *CollectorPermissions) Disable(cfg *MonitoringConfig) {
+	c.disable(cfg)
+}
+
+// String returns a human readable description of the check's result.
+func (c *CollectorPermissions) String() string {
+	if len(c.Missing) == 0 {
+		return fmt.Sprintf("%v: ok", c.Name)
+	}
+
+	return fmt.Sprintf("%v: missing permissions for %v", c.Name,
+		c.Missing)
+}
+
+// CheckPermissions checks whether the given macaroon has the permissions that
+// each collector enabled in the given config needs. RPCs that lnd doesn't list
+// permissions for either don't require a macaroon, or belong to a sub-server
+// that lnd wasn't built with, so they are skipped.
+func CheckPermissions(ctx context.Context, lnd lndclient.LightningClient,
+	macaroon []byte, cfg *MonitoringConfig) ([]CollectorPermissions,
+	error) {
+
+	permissions, err := lnd.ListPermissions(ctx)
+	switch {
+	case status.Code(err) == codes.Unimplemented:
+		return nil, ErrPermissionCheckUnsupported
+
+	case err != nil:
+		return nil, fmt.Errorf("unable to list permissions: %v", err)
+	}
+
+	// Collectors share many RPCs, so we only check each of them once.
+	allowed := make(map[string]bool)
+	checkMethod := func(method string) (bool, error) {
+		if ok, checked := allowed[method]; checked {
+			return ok, nil
+		}
+
+		required, ok := permissions[method]
+		if !ok {
+			allowed[method] = true
+			return true, nil
+		}
+
+		valid, err := lnd.CheckMacaroonPermissions(
+			ctx, macaroon, required, method,
+		)
+		switch status.Code(err) {
+		case codes.OK:
+			allowed[method] = valid
+
+		// lnd reports a macaroon that lacks permissions as an invalid
+		// argument.
+		case codes.InvalidArgument:
+			allowed[method] = false
+
+		case codes.Unimplemented:
+			return false, ErrPermissionCheckUnsupported
+
+		default:
+			return false, fmt.Errorf("unable to check permissions "+
+				"for %v: %v", method, err)
+		}
+
+		return allowed[method], nil
+	}
+
+	var results []CollectorPermissions
+	for _, collector := range allCollectorRPCs {
+		if !collector.enabled(cfg) {
+			continue
+		}
+
+		result := CollectorPermissions{
+			Name:    collector.name,
+			disable: collector.disable,
+		}
+		for _, method := range collector.methods {
+			ok, err := checkMethod(method)
+			if err != nil {
+				return nil, err
+			}
+
+			if !ok {
+				result.Missing = append(result.Missing, method)
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// RequiredPermissions returns the permissions that a macaroon needs for
+// lndmon to connect to lnd and run the collectors enabled in the given
+// config, given the permissions that lnd requires for each of its RPCs. If
+// checkPermissions is set, the permissions needed to check the permissions of
+// the macaroon at startup are included. The permissions are sorted by entity
+// and action.
+func RequiredPermissions(
+	permissions map[string][]lndclient.MacaroonPermission,
+	cfg *MonitoringConfig,
+	checkPermissions bool) []lndclient.MacaroonPermission {
+
+	methods := append([]string(nil), startupMethods...)
+	if checkPermissions {
+		methods = append(methods, permissionCheckMethods...)
+	}
+
+	for _, collector := range allCollectorRPCs {
+		if !collector.enabled(cfg) {
+			continue
+		}
+
+		methods = append(methods, collector.methods...)
+		methods = append(methods, collector.optionalMethods...)
+	}
+
+	seen := make(map[lndclient.MacaroonPermission]bool)
+	var required []lndclient.MacaroonPermission
+	for _, method := range methods {
+		for _, permission := range permissions[method] {
+			if seen[permission] {
+				continue
+			}
+			seen[permission] = true
+
+			required = append(required, permission)
+		}
+	}
+
+	sort.Slice(required, func(i, j int) bool {
+		if required[i].Entity != required[j].Entity {
+			return required[i].Entity < required[j].Entity
+		}
+
+		return required[i].Action < required[j].Action
+	})
+
+	return required
+}
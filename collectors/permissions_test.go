@@ -0,0 +1,189 @@
+package collectors
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// mockPermissionsClient is a mock lightning client that grants a fixed set of
+// permissions.
+type mockPermissionsClient struct {
+	lndclient.LightningClient
+
+	// permissions maps the methods lnd knows about to the permissions
+	// they require.
+	permissions map[string][]lndclient.MacaroonPermission
+
+	// granted is the set of permissions our macaroon has.
+	granted map[lndclient.MacaroonPermission]bool
+
+	// checkErr is returned by all permission checks if set.
+	checkErr error
+
+	// checks counts the permission checks per method.
+	checks map[string]int
+}
+
+// ListPermissions lists all RPC method URIs and their required macaroon
+// permissions.
+func (m *mockPermissionsClient) ListPermissions(
+	context.Context) (map[string][]lndclient.MacaroonPermission, error) {
+
+	return m.permissions, nil
+}
+
+// CheckMacaroonPermissions checks whether our macaroon has the given
+// permissions. Like lnd, it fails with an invalid argument error if it
+// doesn't.
+func (m *mockPermissionsClient) CheckMacaroonPermissions(_ context.Context,
+	_ []byte, permissions []lndclient.MacaroonPermission,
+	method string) (bool, error) {
+
+	m.checks[method]++
+
+	if m.checkErr != nil {
+		return false, m.checkErr
+	}
+
+	for _, permission := range permissions {
+		if !m.granted[permission] {
+			return false, status.Error(
+				codes.InvalidArgument, "permission denied",
+			)
+		}
+	}
+
+	return true, nil
+}
+
+// TestCheckPermissions tests that we report the RPCs of enabled collectors
+// that our macaroon lacks permissions for, and that we can disable those
+// collectors.
+func TestCheckPermissions(t *testing.T) {
+	var (
+		infoRead = lndclient.MacaroonPermission{
+			Entity: "info", Action: "read",
+		}
+		offchainRead = lndclient.MacaroonPermission{
+			Entity: "offchain", Action: "read",
+		}
+	)
+
+	client := &mockPermissionsClient{
+		permissions: map[string][]lndclient.MacaroonPermission{
+			"/lnrpc.Lightning/GetInfo":              {infoRead},
+			"/routerrpc.Router/SubscribeHtlcEvents": {offchainRead},
+			"/routerrpc.Router/TrackPayments":       {offchainRead},
+		},
+		granted: map[lndclient.MacaroonPermission]bool{
+			infoRead: true,
+		},
+		checks: make(map[string]int),
+	}
+
+	cfg := &MonitoringConfig{
+		DisablePayments: true,
+	}
+
+	results, err := CheckPermissions(
+		context.Background(), client, nil, cfg,
+	)
+	require.NoError(t, err)
+
+	missing := make(map[string][]string)
+	for _, result := range results {
+		missing[result.Name] = result.Missing
+	}
+
+	// Methods that lnd doesn't know about are skipped, and disabled
+	// collectors aren't checked.
+	require.Empty(t, missing[collectorChain])
+	require.Empty(t, missing[collectorLeases])
+	require.Equal(
+		t, []string{"/routerrpc.Router/SubscribeHtlcEvents"},
+		missing[collectorHtlcs],
+	)
+	require.NotContains(t, missing, collectorPayments)
+
+	// Each method is only checked once, even if several collectors use
+	// it.
+	require.Equal(t, 1, client.checks["/lnrpc.Lightning/GetInfo"])
+
+	for _, result := range results {
+		if len(result.Missing) > 0 {
+			result.Disable(cfg)
+		}
+	}
+	require.True(t, cfg.DisableHtlc)
+	require.Empty(t, cfg.DisabledCollectors)
+}
+
+// TestCheckPermissionsUnsupported tests that we report lnd not supporting
+// permission checks.
+func TestCheckPermissionsUnsupported(t *testing.T) {
+	client := &mockPermissionsClient{
+		permissions: map[string][]lndclient.MacaroonPermission{
+			"/lnrpc.Lightning/GetInfo": {{
+				Entity: "info", Action: "read",
+			}},
+		},
+		checkErr: status.Error(codes.Unimplemented, "unknown method"),
+		checks:   make(map[string]int),
+	}
+
+	_, err := CheckPermissions(
+		context.Background(), client, nil, &MonitoringConfig{},
+	)
+	require.ErrorIs(t, err, ErrPermissionCheckUnsupported)
+}
+
+// TestRequiredPermissions tests that we compute the minimal set of
+// permissions lndmon needs from the collectors that are enabled.
+func TestRequiredPermissions(t *testing.T) {
+	var (
+		infoRead = lndclient.MacaroonPermission{
+			Entity: "info", Action: "read",
+		}
+		macaroonRead = lndclient.MacaroonPermission{
+			Entity: "macaroon", Action: "read",
+		}
+		offchainRead = lndclient.MacaroonPermission{
+			Entity: "offchain", Action: "read",
+		}
+		onchainRead = lndclient.MacaroonPermission{
+			Entity: "onchain", Action: "read",
+		}
+	)
+
+	permissions := map[string][]lndclient.MacaroonPermission{
+		"/lnrpc.Lightning/GetInfo":                  {infoRead},
+		"/verrpc.Versioner/GetVersion":              {infoRead},
+		"/lnrpc.Lightning/ListPermissions":          {infoRead},
+		"/lnrpc.Lightning/CheckMacaroonPermissions": {macaroonRead},
+		"/lnrpc.Lightning/GetTransactions":          {onchainRead},
+		"/routerrpc.Router/TrackPayments":           {offchainRead},
+		"/lnrpc.Lightning/SendCoins": {{
+			Entity: "onchain", Action: "write",
+		}},
+	}
+
+	// Permissions of disabled collectors aren't required, and neither are
+	// those of the permission check if it is skipped.
+	required := RequiredPermissions(permissions, &MonitoringConfig{
+		DisablePayments: true,
+	}, false)
+	require.Equal(
+		t, []lndclient.MacaroonPermission{infoRead, onchainRead},
+		required,
+	)
+
+	required = RequiredPermissions(permissions, &MonitoringConfig{}, true)
+	require.Equal(t, []lndclient.MacaroonPermission{
+		infoRead, macaroonRead, offchainRead, onchainRead,
+	}, required)
+}
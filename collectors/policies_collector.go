@@ -0,0 +1,492 @@
+package collectors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	policyChanIDLabel     = "chan_id"
+	policyAdvertiserLabel = "advertising_node"
+
+	// policyScopeLabel is the label we use to distinguish between the
+	// policies of our own channels and the policies of our peers'
+	// channels.
+	policyScopeLabel = "scope"
+
+	// policyScopeLocal is used for the policies that we advertise for our
+	// own channels.
+	policyScopeLocal = "local"
+
+	// policyScopeRemote is used for the policies that our peers advertise
+	// for their channels with us.
+	policyScopeRemote = "remote"
+
+	// policyScopePeer is used for the policies of all other channels of
+	// our peers.
+	policyScopePeer = "peer"
+
+	// feeChangeSignLabel is the label we use to distinguish between fee
+	// increases and decreases.
+	feeChangeSignLabel    = "sign"
+	feeChangeSignIncrease = "increase"
+	feeChangeSignDecrease = "decrease"
+)
+
+// policyLabels is the set of labels we use for per channel policy metrics.
+var policyLabels = []string{
+	policyChanIDLabel, policyAdvertiserLabel, policyScopeLabel,
+}
+
+// policyMonitor tracks changes to the routing policies of our own channels and
+// the channels of our peers using lnd's channel graph subscription. Like the
+// htlc monitor, it uses the built-in prometheus types rather than implementing
+// the collector interface.
+type policyMonitor struct {
+	lnd lndclient.LightningClient
+
+	// self is our own node's pubkey.
+	self route.Vertex
+
+	// peers is the set of nodes that we currently have channels with.
+	// It is only accessed from the monitor's main goroutine.
+	peers map[route.Vertex]struct{}
+
+	// policies holds the last known routing policy for each tracked
+	// channel, keyed by channel ID and advertising node. It is only
+	// accessed from the monitor's main goroutine.
+	policies map[uint64]map[route.Vertex]lndclient.RoutingPolicy
+
+	// endpoints holds the two nodes of each tracked channel, so that we
+	// can forget a peer's channels once it's no longer our peer. It is
+	// only accessed from the monitor's main goroutine.
+	endpoints map[uint64][2]route.Vertex
+
+	// updateCounter counts the policy changes per channel and direction.
+	updateCounter *prometheus.CounterVec
+
+	// lastChangeGauge tracks the timestamp of the last policy change per
+	// channel and direction.
+	lastChangeGauge *prometheus.GaugeVec
+
+	// feeRateChangeGauge tracks the last fee rate change per channel and
+	// direction.
+	feeRateChangeGauge *prometheus.GaugeVec
+
+	// feeRateChangeHistogram tracks the magnitude of all fee rate changes.
+	feeRateChangeHistogram *prometheus.HistogramVec
+
+	// quit is closed to signal that we need to shutdown.
+	quit chan struct{}
+
+	wg sync.WaitGroup
+
+	// errChan is a channel that we send any errors that we encounter into.
+	// This channel should be buffered so that it does not block sends.
+	errChan chan<- error
+}
+
+// newPolicyMonitor creates a new policy monitor.
+func newPolicyMonitor(lnd *lndclient.LndServices,
+	errChan chan error) *policyMonitor {
+
+	return &policyMonitor{
+		lnd:  lnd.Client,
+		self: lnd.NodePubkey,
+		policies: make(
+			map[uint64]map[route.Vertex]lndclient.RoutingPolicy,
+		),
+		endpoints: make(map[uint64][2]route.Vertex),
+		updateCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "lnd",
+				Subsystem: "policy",
+				Name:      "updates_total",
+				Help: "count of routing policy changes per " +
+					"channel and direction",
+			}, policyLabels,
+		),
+		lastChangeGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: "lnd",
+				Subsystem: "policy",
+				Name:      "last_change_timestamp_seconds",
+				Help: "timestamp of the last routing policy " +
+					"change per channel and direction",
+			}, policyLabels,
+		),
+		feeRateChangeGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: "lnd",
+				Subsystem: "policy",
+				Name:      "last_fee_rate_change_ppm",
+				Help: "last change of the fee rate per " +
+					"channel and direction in ppm",
+			}, policyLabels,
+		),
+		feeRateChangeHistogram: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: "lnd",
+				Subsystem: "policy",
+				Name:      "fee_rate_change_ppm",
+				Help: "histogram of the magnitude of fee " +
+					"rate changes in ppm",
+				Buckets: prometheus.ExponentialBuckets(
+					1, 2, 15, // 1 to 16384 ppm
+				),
+			}, []string{policyScopeLabel, feeChangeSignLabel},
+		),
+		quit:    make(chan struct{}),
+		errChan: errChan,
+	}
+}
+
+// start subscribes to graph updates, loads the current policies of the
+// channels we track and begins the main event loop of the policy monitor.
+func (p *policyMonitor) start() error {
+	policyLogger.Info("Starting policy monitor")
+
+	// We're restarted whenever lnd was unavailable, so we need a fresh
+	// quit channel each time.
+	p.quit = make(chan struct{})
+
+	// Create a context to subscribe to updates and cancel it on exit so
+	// that lnd can cancel the stream. We subscribe before loading our
+	// initial set of policies so that we don't miss any updates in
+	// between.
+	ctx, cancel := context.WithCancel(context.Background())
+
+	graphUpdates, graphErrChan, err := p.lnd.SubscribeGraph(ctx)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	if err := p.refreshPeers(); err != nil {
+		cancel()
+		return err
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer func() {
+			cancel()
+			p.wg.Done()
+		}()
+
+		ticker := time.NewTicker(cacheRefreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case update, ok := <-graphUpdates:
+				if !ok {
+					err := errors.New("graph update " +
+						"stream terminated")
+					sendError(p.errChan, p.quit, err)
+					return
+				}
+
+				p.processGraphUpdate(update)
+
+			case err, ok := <-graphErrChan:
+				sendError(p.errChan, p.quit, fmt.Errorf(
+					"graph update stream exited: %v, "+
+						"closed: %v", err, ok,
+				))
+				return
+
+			// Periodically refresh our set of peers, so that we
+			// also track the channels of peers we've opened
+			// channels with since the last refresh.
+			case <-ticker.C:
+				if err := p.refreshPeers(); err != nil {
+					sendError(p.errChan, p.quit, err)
+					return
+				}
+
+			case <-p.quit:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// stop sends the policy monitor's goroutines the instruction to shutdown and
+// waits for them to exit.
+func (p *policyMonitor) stop() {
+	policyLogger.Info("Stopping policy monitor")
+
+	close(p.quit)
+	p.wg.Wait()
+}
+
+// collectors returns all of the collectors that the policy monitor uses.
+func (p *policyMonitor) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		p.updateCounter, p.lastChangeGauge, p.feeRateChangeGauge,
+		p.feeRateChangeHistogram,
+	}
+}
+
+// refreshPeers updates our set of peers from our list of channels and loads
+// the current policies of our own channels and the channels of any new peers.
+func (p *policyMonitor) refreshPeers() error {
+	channels, err := p.lnd.ListChannels(context.Background(), false, false)
+	if err != nil {
+		return fmt.Errorf("policy monitor ListChannels failed "+
+			"with: %v", err)
+	}
+
+	peers := make(map[route.Vertex]struct{}, len(channels))
+	for _, channel := range channels {
+		peers[channel.PubKeyBytes] = struct{}{}
+	}
+
+	// We only need to load our own policies on the very first refresh,
+	// after that we'll receive any changes through the subscription.
+	var newNodes []route.Vertex
+	if p.peers == nil {
+		newNodes = append(newNodes, p.self)
+	}
+	for peer := range peers {
+		if _, ok := p.peers[peer]; !ok {
+			newNodes = append(newNodes, peer)
+		}
+	}
+
+	for _, node := range newNodes {
+		nodeInfo, err := p.lnd.GetNodeInfo(
+			context.Background(), node, true,
+		)
+
+		// A peer that only has private channels isn't part of our
+		// graph, so this isn't an error.
+		if err != nil {
+			policyLogger.Debugf("Unable to load policies for "+
+				"%v: %v", node, err)
+
+			continue
+		}
+
+		for _, edge := range nodeInfo.Channels {
+			p.endpoints[edge.ChannelID] = [2]route.Vertex{
+				edge.Node1, edge.Node2,
+			}
+			p.loadPolicy(
+				edge.ChannelID, edge.Node1, edge.Node1Policy,
+			)
+			p.loadPolicy(
+				edge.ChannelID, edge.Node2, edge.Node2Policy,
+			)
+		}
+	}
+
+	p.peers = peers
+	p.prunePolicies()
+
+	return nil
+}
+
+// prunePolicies forgets all channels that no longer belong to us or one of our
+// peers. Otherwise the channels of a former peer would be exported forever,
+// since they don't close from our point of view.
+func (p *policyMonitor) prunePolicies() {
+	for chanID, nodes := range p.endpoints {
+		if _, ok := p.policyScope(nodes[0], nodes[1]); ok {
+			continue
+		}
+
+		p.forgetChannel(chanID)
+	}
+}
+
+// forgetChannel removes the last known policies and the per channel series of
+// the given channel.
+func (p *policyMonitor) forgetChannel(chanID uint64) {
+	delete(p.policies, chanID)
+	delete(p.endpoints, chanID)
+
+	labels := prometheus.Labels{
+		policyChanIDLabel: formatChanID(chanID),
+	}
+	p.updateCounter.DeletePartialMatch(labels)
+	p.lastChangeGauge.DeletePartialMatch(labels)
+	p.feeRateChangeGauge.DeletePartialMatch(labels)
+}
+
+// loadPolicy stores the given policy as the last known policy of a channel,
+// unless we already know about a policy for it.
+func (p *policyMonitor) loadPolicy(chanID uint64, node route.Vertex,
+	policy *lndclient.RoutingPolicy) {
+
+	if policy == nil {
+		return
+	}
+
+	if _, ok := p.policies[chanID]; !ok {
+		p.policies[chanID] = make(
+			map[route.Vertex]lndclient.RoutingPolicy,
+		)
+	}
+
+	if _, ok := p.policies[chanID][node]; !ok {
+		p.policies[chanID][node] = *policy
+	}
+}
+
+// policyScope returns the scope of a channel update given the node that
+// advertised it and the node on the other end of the channel. False is
+// returned if the update doesn't belong to a channel that we track.
+func (p *policyMonitor) policyScope(advertisingNode,
+	connectingNode route.Vertex) (string, bool) {
+
+	_, advertiserIsPeer := p.peers[advertisingNode]
+	_, connectingIsPeer := p.peers[connectingNode]
+
+	switch {
+	case advertisingNode == p.self:
+		return policyScopeLocal, true
+
+	case connectingNode == p.self:
+		return policyScopeRemote, true
+
+	case advertiserIsPeer || connectingIsPeer:
+		return policyScopePeer, true
+
+	default:
+		return "", false
+	}
+}
+
+// processGraphUpdate records all policy changes and channel closes of the
+// channels we track.
+func (p *policyMonitor) processGraphUpdate(
+	update *lndclient.GraphTopologyUpdate) {
+
+	for _, edgeUpdate := range update.ChannelEdgeUpdates {
+		scope, ok := p.policyScope(
+			edgeUpdate.AdvertisingNode, edgeUpdate.ConnectingNode,
+		)
+		if !ok {
+			continue
+		}
+
+		p.recordPolicy(edgeUpdate, scope)
+	}
+
+	for _, closeUpdate := range update.ChannelCloseUpdates {
+		chanID := closeUpdate.ChannelID.ToUint64()
+		if _, ok := p.endpoints[chanID]; !ok {
+			continue
+		}
+
+		// Remove the per channel series of the closed channel, so that
+		// we don't keep exporting them forever.
+		p.forgetChannel(chanID)
+
+		policyLogger.Infof("Channel closed: chan_id=%v "+
+			"closed_height=%v", chanID, closeUpdate.ClosedHeight)
+	}
+}
+
+// recordPolicy compares a policy update with the last known policy of the
+// channel and records it in our metrics if it changed.
+func (p *policyMonitor) recordPolicy(edgeUpdate lndclient.ChannelEdgeUpdate,
+	scope string) {
+
+	var (
+		chanID    = edgeUpdate.ChannelID.ToUint64()
+		node      = edgeUpdate.AdvertisingNode
+		newPolicy = edgeUpdate.RoutingPolicy
+	)
+
+	oldPolicy, known := p.policies[chanID][node]
+
+	// Nodes periodically re-broadcast their policies to keep their
+	// channels alive, we don't count those as changes.
+	if known && policiesEqual(oldPolicy, newPolicy) {
+		return
+	}
+
+	if _, ok := p.policies[chanID]; !ok {
+		p.policies[chanID] = make(
+			map[route.Vertex]lndclient.RoutingPolicy,
+		)
+	}
+	p.policies[chanID][node] = newPolicy
+	p.endpoints[chanID] = [2]route.Vertex{node, edgeUpdate.ConnectingNode}
+
+	labels := prometheus.Labels{
+		policyChanIDLabel:     formatChanID(chanID),
+		policyAdvertiserLabel: node.String(),
+		policyScopeLabel:      scope,
+	}
+
+	p.updateCounter.With(labels).Inc()
+	p.lastChangeGauge.With(labels).Set(
+		float64(newPolicy.LastUpdate.Unix()),
+	)
+
+	// If we didn't know about the previous policy, we can't compute any
+	// differences.
+	if !known {
+		policyLogger.Infof("New policy: chan_id=%v "+
+			"advertising_node=%v scope=%v fee_base_msat=%v "+
+			"fee_rate_ppm=%v time_lock_delta=%v min_htlc_msat=%v "+
+			"max_htlc_msat=%v disabled=%v", chanID, node, scope,
+			newPolicy.FeeBaseMsat, newPolicy.FeeRateMilliMsat,
+			newPolicy.TimeLockDelta, newPolicy.MinHtlcMsat,
+			newPolicy.MaxHtlcMsat, newPolicy.Disabled)
+
+		return
+	}
+
+	feeRateChange := newPolicy.FeeRateMilliMsat - oldPolicy.FeeRateMilliMsat
+	p.feeRateChangeGauge.With(labels).Set(float64(feeRateChange))
+
+	if feeRateChange != 0 {
+		sign := feeChangeSignIncrease
+		if feeRateChange < 0 {
+			sign = feeChangeSignDecrease
+		}
+
+		p.feeRateChangeHistogram.WithLabelValues(scope, sign).Observe(
+			math.Abs(float64(feeRateChange)),
+		)
+	}
+
+	policyLogger.Infof("Policy changed: chan_id=%v advertising_node=%v "+
+		"scope=%v fee_base_msat=%v->%v fee_rate_ppm=%v->%v "+
+		"time_lock_delta=%v->%v min_htlc_msat=%v->%v "+
+		"max_htlc_msat=%v->%v disabled=%v->%v", chanID, node, scope,
+		oldPolicy.FeeBaseMsat, newPolicy.FeeBaseMsat,
+		oldPolicy.FeeRateMilliMsat, newPolicy.FeeRateMilliMsat,
+		oldPolicy.TimeLockDelta, newPolicy.TimeLockDelta,
+		oldPolicy.MinHtlcMsat, newPolicy.MinHtlcMsat,
+		oldPolicy.MaxHtlcMsat, newPolicy.MaxHtlcMsat,
+		oldPolicy.Disabled, newPolicy.Disabled)
+}
+
+// policiesEqual returns true if the two policies have the same forwarding
+// parameters, ignoring their update timestamps.
+func policiesEqual(a, b lndclient.RoutingPolicy) bool {
+	return a.TimeLockDelta == b.TimeLockDelta &&
+		a.MinHtlcMsat == b.MinHtlcMsat &&
+		a.MaxHtlcMsat == b.MaxHtlcMsat &&
+		a.FeeBaseMsat == b.FeeBaseMsat &&
+		a.FeeRateMilliMsat == b.FeeRateMilliMsat &&
+		a.Disabled == b.Disabled &&
+		a.InboundBaseFeeMsat == b.InboundBaseFeeMsat &&
+		a.InboundFeeRatePPM == b.InboundFeeRatePPM
+}
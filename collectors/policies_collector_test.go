@@ -0,0 +1,187 @@
+package collectors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPolicyMonitorProcessGraphUpdate tests that the policy monitor only
+// records actual policy changes of the channels it tracks.
+func TestPolicyMonitorProcessGraphUpdate(t *testing.T) {
+	var (
+		self     = route.Vertex{1}
+		peer     = route.Vertex{2}
+		stranger = route.Vertex{3}
+		other    = route.Vertex{4}
+
+		ourChan   = lnwire.NewShortChanIDFromInt(100)
+		peerChan  = lnwire.NewShortChanIDFromInt(200)
+		otherChan = lnwire.NewShortChanIDFromInt(300)
+	)
+
+	monitor := newPolicyMonitor(
+		&lndclient.LndServices{NodePubkey: self}, make(chan error, 1),
+	)
+	monitor.peers = map[route.Vertex]struct{}{
+		peer: {},
+	}
+
+	policy := lndclient.RoutingPolicy{
+		FeeBaseMsat:      1000,
+		FeeRateMilliMsat: 100,
+		LastUpdate:       time.Unix(1000, 0),
+	}
+	monitor.loadPolicy(ourChan.ToUint64(), self, &policy)
+
+	changedPolicy := policy
+	changedPolicy.FeeRateMilliMsat = 150
+	changedPolicy.LastUpdate = time.Unix(2000, 0)
+
+	keepAlivePolicy := policy
+	keepAlivePolicy.LastUpdate = time.Unix(3000, 0)
+
+	monitor.processGraphUpdate(&lndclient.GraphTopologyUpdate{
+		ChannelEdgeUpdates: []lndclient.ChannelEdgeUpdate{
+			// A keep-alive update of our own policy shouldn't be
+			// counted.
+			{
+				ChannelID:       ourChan,
+				RoutingPolicy:   keepAlivePolicy,
+				AdvertisingNode: self,
+				ConnectingNode:  peer,
+			},
+			// The first policy we see from our peer is counted.
+			{
+				ChannelID:       ourChan,
+				RoutingPolicy:   policy,
+				AdvertisingNode: peer,
+				ConnectingNode:  self,
+			},
+			// A policy of another channel of our peer.
+			{
+				ChannelID:       peerChan,
+				RoutingPolicy:   policy,
+				AdvertisingNode: stranger,
+				ConnectingNode:  peer,
+			},
+			// A channel we don't track at all.
+			{
+				ChannelID:       otherChan,
+				RoutingPolicy:   policy,
+				AdvertisingNode: stranger,
+				ConnectingNode:  other,
+			},
+		},
+	})
+
+	require.Equal(t, 2, testutil.CollectAndCount(monitor.updateCounter))
+	require.Equal(t, 0, testutil.CollectAndCount(
+		monitor.feeRateChangeGauge,
+	))
+
+	remoteCounter := monitor.updateCounter.WithLabelValues(
+		"100", peer.String(), policyScopeRemote,
+	)
+	require.Equal(t, 1.0, testutil.ToFloat64(remoteCounter))
+
+	peerCounter := monitor.updateCounter.WithLabelValues(
+		"200", stranger.String(), policyScopePeer,
+	)
+	require.Equal(t, 1.0, testutil.ToFloat64(peerCounter))
+
+	// Now we change our own fee rate, which should be recorded.
+	monitor.processGraphUpdate(&lndclient.GraphTopologyUpdate{
+		ChannelEdgeUpdates: []lndclient.ChannelEdgeUpdate{{
+			ChannelID:       ourChan,
+			RoutingPolicy:   changedPolicy,
+			AdvertisingNode: self,
+			ConnectingNode:  peer,
+		}},
+	})
+
+	labels := []string{"100", self.String(), policyScopeLocal}
+	require.Equal(t, 1.0, testutil.ToFloat64(
+		monitor.updateCounter.WithLabelValues(labels...),
+	))
+	require.Equal(t, 2000.0, testutil.ToFloat64(
+		monitor.lastChangeGauge.WithLabelValues(labels...),
+	))
+	require.Equal(t, 50.0, testutil.ToFloat64(
+		monitor.feeRateChangeGauge.WithLabelValues(labels...),
+	))
+
+	// Closing our channel removes all of its series.
+	monitor.processGraphUpdate(&lndclient.GraphTopologyUpdate{
+		ChannelCloseUpdates: []lndclient.ChannelCloseUpdate{{
+			ChannelID: ourChan,
+		}},
+	})
+
+	require.Equal(t, 1, testutil.CollectAndCount(monitor.updateCounter))
+	require.NotContains(t, monitor.policies, ourChan.ToUint64())
+}
+
+// TestPolicyMonitorPrunePolicies tests that the channels of a former peer are
+// forgotten along with their series, while our own channels are kept.
+func TestPolicyMonitorPrunePolicies(t *testing.T) {
+	var (
+		self     = route.Vertex{1}
+		peer     = route.Vertex{2}
+		stranger = route.Vertex{3}
+
+		ourChan  = lnwire.NewShortChanIDFromInt(100)
+		peerChan = lnwire.NewShortChanIDFromInt(200)
+	)
+
+	monitor := newPolicyMonitor(
+		&lndclient.LndServices{NodePubkey: self}, make(chan error, 1),
+	)
+	monitor.peers = map[route.Vertex]struct{}{
+		peer: {},
+	}
+
+	policy := lndclient.RoutingPolicy{
+		FeeRateMilliMsat: 100,
+		LastUpdate:       time.Unix(1000, 0),
+	}
+	monitor.processGraphUpdate(&lndclient.GraphTopologyUpdate{
+		ChannelEdgeUpdates: []lndclient.ChannelEdgeUpdate{
+			{
+				ChannelID:       ourChan,
+				RoutingPolicy:   policy,
+				AdvertisingNode: peer,
+				ConnectingNode:  self,
+			},
+			{
+				ChannelID:       peerChan,
+				RoutingPolicy:   policy,
+				AdvertisingNode: stranger,
+				ConnectingNode:  peer,
+			},
+		},
+	})
+	require.Equal(t, 2, testutil.CollectAndCount(monitor.updateCounter))
+
+	// As long as the node is our peer, nothing is pruned.
+	monitor.prunePolicies()
+	require.Equal(t, 2, testutil.CollectAndCount(monitor.updateCounter))
+
+	// Once it's no longer our peer, only our own channel is kept.
+	monitor.peers = map[route.Vertex]struct{}{}
+	monitor.prunePolicies()
+
+	require.Equal(t, 1, testutil.CollectAndCount(monitor.updateCounter))
+	require.Equal(t, 1.0, testutil.ToFloat64(
+		monitor.updateCounter.WithLabelValues(
+			"100", peer.String(), policyScopeRemote,
+		),
+	))
+	require.NotContains(t, monitor.policies, peerChan.ToUint64())
+	require.NotContains(t, monitor.endpoints, peerChan.ToUint64())
+}
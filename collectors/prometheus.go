@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/btcsuite/btcd/btcutil"
@@ -23,6 +24,16 @@ var (
 	defaultLndmonDir   = btcutil.AppDataDir("lndmon", false)
 )
 
+const (
+	// MetricsVersion1 is the original version of our metric definitions.
+	MetricsVersion1 = 1
+
+	// MetricsVersion2 exports lnd_peer_count and
+	// lnd_peer_ping_time_microsecond as gauges rather than counters, and
+	// reports the ping time in microseconds rather than nanoseconds.
+	MetricsVersion2 = 2
+)
+
 // PrometheusExporter is a metric exporter that exports relevant lnd metrics
 // such as routing policies to track how Lightning fees change over time.
 type PrometheusExporter struct {
@@ -32,15 +43,49 @@ type PrometheusExporter struct {
 
 	monitoringCfg *MonitoringConfig
 
-	htlcMonitor     *htlcMonitor
-	paymentsMonitor *paymentsMonitor
+	// aliases resolves the aliases of our peers in the background.
+	aliases *aliasCache
+
+	htlcMonitor       *htlcMonitor
+	paymentsMonitor   *paymentsMonitor
+	policyMonitor     *policyMonitor
+	gossipMonitor     *gossipMonitor
+	peerEventsMonitor *peerEventsMonitor
+
+	// walletStateMonitor is nil if lndmon's macaroon lacks the
+	// permissions it needs.
+	walletStateMonitor *walletStateMonitor
+
+	// blockEventsMonitor is nil if block metrics are disabled.
+	blockEventsMonitor *blockEventsMonitor
+
+	// centralityMonitor is nil if centrality metrics are disabled.
+	centralityMonitor *centralityMonitor
+
+	// runningMonitors holds the stop functions of the monitors that are
+	// currently running, in the order they were started.
+	runningMonitors []func()
 
 	// collectors is the exporter's active set of collectors.
 	collectors []prometheus.Collector
 
+	// lndGracePeriod is how long we wait for lnd to be reported as
+	// unavailable after one of our collectors failed, before we consider
+	// the failure fatal.
+	lndGracePeriod time.Duration
+
 	// errChan is an error channel that we receive errors from our
 	// collectors on.
 	errChan <-chan error
+
+	// errors is the channel that we forward the errors we can't recover
+	// from to if we know lnd's state.
+	errors chan error
+
+	// quit is closed to signal that we need to shutdown.
+	quit chan struct{}
+
+	wg sync.WaitGroup
 }
 
 // PrometheusConfig is the set of configuration data that specifies the
@@ -76,6 +121,49 @@ type MonitoringConfig struct {
 	// DisablePayments disables collection of payment metrics
 	DisablePayments bool
 
+	// DisablePolicyUpdates disables collection of channel policy update
+	// metrics.
+	DisablePolicyUpdates bool
+
+	// DisableGossip disables collection of gossip metrics. If set, the
+	// full graph is fetched from lnd on every scrape instead of being
+	// maintained in memory.
+	DisableGossip bool
+
+	// DisablePeerEvents disables collection of peer connection event
+	// metrics.
+	DisablePeerEvents bool
+
+	// MetricsVersion is the version of metric definitions to export. Newer
+	// versions fix mistakes in existing metrics that would break
+	// dashboards and alerts relying on them.
+	MetricsVersion int
+
+	// GraphStats specifies how distributions of values across the channel
+	// graph are exported. If nil, only the summary gauges are exported.
+	GraphStats *GraphStatsConfig
+
+	// Centrality specifies how the centrality and reachability metrics of
+	// our node are computed. If nil, they are disabled.
+	Centrality *CentralityConfig
+
+	// FeeEstimates specifies which fee estimates of lnd are exported. If
+	// nil, they are disabled.
+	FeeEstimates *FeeEstimatesConfig
+
+	// Blocks specifies how the blocks lnd is notified of are monitored. If
+	// nil, block metrics are disabled.
+	Blocks *BlocksConfig
+
+	// DataDir is the directory lndmon persists its state in. If empty,
+	// no state is persisted.
+	DataDir string
+
+	// DisabledCollectors holds the names of collectors that are always
+	// enabled otherwise, but were disabled because lndmon's macaroon
+	// lacks the permissions they need.
+	DisabledCollectors map[string]bool
+
 	// ProgramStartTime stores a best-effort estimate of when lnd/lndmon was
 	// started.
 	ProgramStartTime time.Time
@@ -90,35 +178,182 @@ func DefaultConfig() *PrometheusConfig {
 	}
 }
 
+// lndGracePeriod is how long we wait for lnd to be reported as unavailable
+// after one of our collectors failed, before we consider the failure fatal.
+const lndGracePeriod = 10 * time.Second
+
+// errChanBufferSize is the size of the buffer of the error channel that our
+// collectors and monitors share.
+const errChanBufferSize = 32
+
+// sendError sends an error into the exporter's error channel, unless the
+// monitor sending it is stopped first. Since we stop consuming errors after
+// the first one, monitors must use it so that a full error channel doesn't
+// block them, and with them our shutdown.
+func sendError(errChan chan<- error, quit <-chan struct{}, err error) {
+	select {
+	case errChan <- err:
+	case <-quit:
+	}
+}
+
 // NewPrometheusExporter makes a new instance of the PrometheusExporter given
 // the address to listen for Prometheus on and an lnd gRPC client.
 func NewPrometheusExporter(cfg *PrometheusConfig, lnd *lndclient.LndServices,
 	monitoringCfg *MonitoringConfig,
 	quitChan chan struct{}) *PrometheusExporter {
 
-	// We have six collectors and a htlc monitor running, so we buffer our
-	// error channel by 8 so that we do not need to consume all errors from
-	// this channel (on the first one, we'll start shutting down, but a few
-	// could arrive quickly in the case where lnd is shutting down).
-	errChan := make(chan error, 8)
+	// All of our collectors and monitors send their errors into this
+	// channel, but we only consume the first one and then start shutting
+	// down. Many more could arrive quickly in the case where lnd is
+	// shutting down, so the monitors use sendError to stop sending once
+	// they are stopped, and the buffer lets the scrape collectors finish
+	// their current scrape.
+	errChan := make(chan error, errChanBufferSize)
 
 	htlcMonitor := newHtlcMonitor(lnd.Router, errChan)
 
 	// Create payments monitor.
 	paymentsMonitor := newPaymentsMonitor(lnd, errChan)
 
+	// Create the policy monitor.
+	policyMonitor := newPolicyMonitor(lnd, errChan)
+
+	// Create the gossip monitor.
+	gossipMonitor := newGossipMonitor(
+		lnd.Client, lnd.NodePubkey, errChan,
+	)
+
+	// Create the peer events monitor.
+	peerEventsMonitor := newPeerEventsMonitor(lnd.Client, errChan)
+
+	// Create the wallet state monitor.
+	walletStateMonitor := newWalletStateMonitor(
+		lnd, monitoringCfg.ProgramStartTime,
+	)
+
+	// The aliases of our peers are exported by both the channels and the
+	// peer collector, so they share one cache.
+	aliases := newAliasCache(lnd.Client)
+
 	chanCollector := NewChannelsCollector(
-		lnd.Client, errChan, quitChan, monitoringCfg,
+		lnd.Client, aliases, errChan, quitChan, monitoringCfg,
 	)
 
-	collectors := []prometheus.Collector{
-		NewChainCollector(lnd.Client, errChan),
-		chanCollector,
-		NewWalletCollector(lnd, errChan),
-		NewPeerCollector(lnd.Client, errChan),
-		NewInfoCollector(lnd.Client, errChan),
-		NewStateCollector(lnd, errChan, monitoringCfg.ProgramStartTime),
-		NewWtClientCollector(lnd, errChan),
+	// If the gossip monitor is enabled, we serve the graph metrics from
+	// its in-memory graph rather than fetching the full graph from lnd on
+	// every scrape. The graph metrics of our peers are only served from
+	// the in-memory graph, since fetching the full graph for them as well
+	// would double the cost of every scrape.
+	var graph, peerGraph graphSource
+	switch {
+	// Without graph metrics, we don't need the graph at all.
+	case monitoringCfg.DisableGraph:
+
+	case monitoringCfg.DisableGossip:
+		graph = lnd.Client
+
+	default:
+		graph = gossipMonitor
+		peerGraph = gossipMonitor
+	}
+
+	var collectors []prometheus.Collector
+	for _, base := range []struct {
+		name       string
+		collectors []prometheus.Collector
+	}{{
+		name: collectorChain,
+		collectors: []prometheus.Collector{
+			NewChainCollector(lnd.Client, errChan),
+		},
+	}, {
+		name:       collectorChannels,
+		collectors: []prometheus.Collector{chanCollector},
+	}, {
+		name: collectorWallet,
+		collectors: []prometheus.Collector{
+			NewWalletCollector(lnd, errChan),
+		},
+	}, {
+		name: collectorAnchorReserve,
+		collectors: []prometheus.Collector{
+			NewAnchorReserveCollector(lnd, errChan),
+		},
+	}, {
+		name: collectorLeases,
+		collectors: []prometheus.Collector{
+			NewLeasesCollector(lnd.WalletKit, errChan),
+		},
+	}, {
+		name: collectorSweeps,
+		collectors: []prometheus.Collector{
+			NewSweepsCollector(lnd, errChan),
+		},
+	}, {
+		name: collectorPeers,
+		collectors: []prometheus.Collector{
+			NewPeerCollector(
+				lnd.Client, aliases, peerGraph,
+				monitoringCfg.MetricsVersion, errChan,
+			),
+		},
+	}, {
+		name: collectorInfo,
+		collectors: []prometheus.Collector{
+			NewInfoCollector(lnd, errChan),
+		},
+	}, {
+		name: collectorWtClient,
+		collectors: []prometheus.Collector{
+			NewWtClientCollector(lnd, errChan),
+		},
+	}, {
+		name: collectorWtServer,
+		collectors: []prometheus.Collector{
+			NewWtServerCollector(lnd, errChan),
+		},
+	}, {
+		name: collectorTransactions,
+		collectors: []prometheus.Collector{
+			NewTransactionsCollector(
+				lnd.Client, monitoringCfg.DataDir,
+				lnd.ChainParams.Name, errChan,
+			),
+		},
+	}, {
+		name:       collectorWalletState,
+		collectors: walletStateMonitor.collectors(),
+	}} {
+		// Collectors that are always enabled otherwise may have been
+		// disabled because our macaroon lacks their permissions.
+		if monitoringCfg.DisabledCollectors[base.name] {
+			continue
+		}
+
+		collectors = append(collectors, base.collectors...)
+	}
+
+	if monitoringCfg.DisabledCollectors[collectorWalletState] {
+		walletStateMonitor = nil
+	}
+
+	feeEstimatesCfg := monitoringCfg.FeeEstimates
+	if feeEstimatesCfg != nil && !feeEstimatesCfg.Disable {
+		collectors = append(collectors, NewFeeEstimateCollector(
+			lnd, feeEstimatesCfg, errChan,
+		))
+	}
+
+	var blockEventsMonitor *blockEventsMonitor
+	blocksCfg := monitoringCfg.Blocks
+	if blocksCfg != nil && !blocksCfg.Disable {
+		blockEventsMonitor = newBlockEventsMonitor(
+			lnd, blocksCfg, errChan,
+		)
+		collectors = append(
+			collectors, blockEventsMonitor.collectors()...,
+		)
 	}
 
 	if !monitoringCfg.DisableHtlc {
@@ -131,20 +366,60 @@ func NewPrometheusExporter(cfg *PrometheusConfig, lnd *lndclient.LndServices,
 		)
 	}
 
+	var centralityMonitor *centralityMonitor
 	if !monitoringCfg.DisableGraph {
+		if !monitoringCfg.DisableGossip {
+			collectors = append(
+				collectors, gossipMonitor.collectors()...,
+			)
+		}
+
+		graphCollector := NewGraphCollector(
+			lnd.Client, graph, monitoringCfg.GraphStats, errChan,
+		)
+		collectors = append(collectors, graphCollector)
+
+		// Our node's centrality is computed from the same graph, but
+		// on a slower schedule since it is expensive to compute.
+		centralityCfg := monitoringCfg.Centrality
+		if centralityCfg != nil && !centralityCfg.Disable {
+			centralityMonitor = newCentralityMonitor(
+				centralityCfg, graph, lnd.NodePubkey, errChan,
+			)
+			collectors = append(
+				collectors, centralityMonitor.collectors()...,
+			)
+		}
+	}
+
+	if !monitoringCfg.DisablePolicyUpdates {
+		collectors = append(collectors, policyMonitor.collectors()...)
+	}
+
+	if !monitoringCfg.DisablePeerEvents {
 		collectors = append(
-			collectors, NewGraphCollector(lnd.Client, errChan),
+			collectors, peerEventsMonitor.collectors()...,
 		)
 	}
 
 	return &PrometheusExporter{
-		cfg:             cfg,
-		lnd:             lnd,
-		monitoringCfg:   monitoringCfg,
-		collectors:      collectors,
-		htlcMonitor:     htlcMonitor,
-		paymentsMonitor: paymentsMonitor,
-		errChan:         errChan,
+		cfg:                cfg,
+		lnd:                lnd,
+		monitoringCfg:      monitoringCfg,
+		aliases:            aliases,
+		collectors:         collectors,
+		htlcMonitor:        htlcMonitor,
+		paymentsMonitor:    paymentsMonitor,
+		policyMonitor:      policyMonitor,
+		gossipMonitor:      gossipMonitor,
+		centralityMonitor:  centralityMonitor,
+		peerEventsMonitor:  peerEventsMonitor,
+		walletStateMonitor: walletStateMonitor,
+		blockEventsMonitor: blockEventsMonitor,
+		lndGracePeriod:     lndGracePeriod,
+		errChan:            errChan,
+		errors:             make(chan error, 1),
+		quit:               make(chan struct{}),
 	}
 }
 
@@ -172,21 +447,28 @@ func (p *PrometheusExporter) Start() error {
 		return err
 	}
 
-	// Start the htlc monitor goroutine. This will subscribe to htlcs and
-	// update all of our routing-related metrics.
-	if !p.monitoringCfg.DisableHtlc {
-		if err := p.htlcMonitor.start(); err != nil {
+	// Start looking up the aliases of our peers in the background, so
+	// that our scrapes don't need to wait for them.
+	p.aliases.start()
+
+	// Start the wallet state monitor goroutine first. This will subscribe
+	// to lnd's state and track its restarts, and tells us when to restart
+	// our other monitors.
+	if p.walletStateMonitor != nil {
+		if err := p.walletStateMonitor.start(); err != nil {
 			return err
 		}
 	}
 
-	// Start the payment monitor goroutine. This will subscribe to receive
-	// update for all payments made by lnd and update our payments related
-	// metrics.
-	if !p.monitoringCfg.DisablePayments {
-		if err := p.paymentsMonitor.start(); err != nil {
-			return err
-		}
+	if err := p.startMonitors(); err != nil {
+		return err
+	}
+
+	// Our monitors fail whenever lnd is unavailable. If we know lnd's
+	// state, we restart them once lnd is back rather than exiting.
+	if p.walletStateMonitor != nil {
+		p.wg.Add(1)
+		go p.superviseMonitors()
 	}
 
 	// Finally, we'll launch the HTTP server that Prometheus will use to
@@ -220,19 +502,211 @@ func (p *PrometheusExporter) Start() error {
 // before returning.
 func (p *PrometheusExporter) Stop() {
 	log.Println("Stopping Prometheus Exporter")
+
+	close(p.quit)
+	p.wg.Wait()
+
+	p.stopMonitors()
+
+	if p.walletStateMonitor != nil {
+		p.walletStateMonitor.stop()
+	}
+
+	p.aliases.stop()
+}
+
+// startMonitors starts all of our enabled monitors, except for the wallet
+// state monitor. If one of them fails to start, the ones that already started
+// are stopped again.
+func (p *PrometheusExporter) startMonitors() error {
+	// Start the htlc monitor goroutine. This will subscribe to htlcs and
+	// update all of our routing-related metrics.
 	if !p.monitoringCfg.DisableHtlc {
-		p.htlcMonitor.stop()
+		if err := p.htlcMonitor.start(); err != nil {
+			p.stopMonitors()
+			return err
+		}
+		p.runningMonitors = append(
+			p.runningMonitors, p.htlcMonitor.stop,
+		)
 	}
 
+	// Start the payment monitor goroutine. This will subscribe to receive
+	// update for all payments made by lnd and update our payments related
+	// metrics.
 	if !p.monitoringCfg.DisablePayments {
-		p.paymentsMonitor.stop()
+		if err := p.paymentsMonitor.start(); err != nil {
+			p.stopMonitors()
+			return err
+		}
+		p.runningMonitors = append(
+			p.runningMonitors, p.paymentsMonitor.stop,
+		)
 	}
+
+	// Start the gossip monitor goroutine. This will subscribe to graph
+	// updates and maintain the in-memory graph that the graph collector
+	// uses.
+	if p.gossipEnabled() {
+		if err := p.gossipMonitor.start(); err != nil {
+			p.stopMonitors()
+			return err
+		}
+		p.runningMonitors = append(
+			p.runningMonitors, p.gossipMonitor.stop,
+		)
+	}
+
+	// Start the centrality monitor goroutine. This needs to happen after
+	// the gossip monitor has started, so that its first computation is
+	// based on the full graph.
+	if p.centralityMonitor != nil {
+		p.centralityMonitor.start()
+		p.runningMonitors = append(
+			p.runningMonitors, p.centralityMonitor.stop,
+		)
+	}
+
+	// Start the policy monitor goroutine. This will subscribe to graph
+	// updates and track policy changes of our and our peers' channels.
+	if !p.monitoringCfg.DisablePolicyUpdates {
+		if err := p.policyMonitor.start(); err != nil {
+			p.stopMonitors()
+			return err
+		}
+		p.runningMonitors = append(
+			p.runningMonitors, p.policyMonitor.stop,
+		)
+	}
+
+	// Start the peer events monitor goroutine. This will subscribe to
+	// peer events and track how often our peers connect and disconnect.
+	if !p.monitoringCfg.DisablePeerEvents {
+		if err := p.peerEventsMonitor.start(); err != nil {
+			p.stopMonitors()
+			return err
+		}
+		p.runningMonitors = append(
+			p.runningMonitors, p.peerEventsMonitor.stop,
+		)
+	}
+
+	// Start the block events monitor goroutine. This will subscribe to
+	// block notifications and track how regularly blocks arrive.
+	if p.blockEventsMonitor != nil {
+		if err := p.blockEventsMonitor.start(); err != nil {
+			p.stopMonitors()
+			return err
+		}
+		p.runningMonitors = append(
+			p.runningMonitors, p.blockEventsMonitor.stop,
+		)
+	}
+
+	return nil
+}
+
+// stopMonitors stops the monitors that are currently running, in the reverse
+// order of starting them.
+func (p *PrometheusExporter) stopMonitors() {
+	for i := len(p.runningMonitors) - 1; i >= 0; i-- {
+		p.runningMonitors[i]()
+	}
+	p.runningMonitors = nil
+}
+
+// superviseMonitors stops our monitors while lnd is unavailable and restarts
+// them once lnd's server is active again. Since our collectors and monitors
+// fail while lnd is unavailable, we only forward their errors if lnd is
+// still active after a grace period.
+func (p *PrometheusExporter) superviseMonitors() {
+	defer p.wg.Done()
+
+	running := true
+	for {
+		// Depending on whether our monitors are running, we wait for
+		// lnd to become unavailable or to be active again.
+		var lndInactive, lndActive <-chan struct{}
+		if running {
+			lndInactive = p.walletStateMonitor.lndInactive()
+		} else {
+			lndActive = p.walletStateMonitor.lndActive()
+		}
+
+		select {
+		case err := <-p.errChan:
+			if !running || p.lndUnavailable() {
+				Logger.Debugf("Ignoring error while lnd is "+
+					"unavailable: %v", err)
+				continue
+			}
+
+			p.errors <- err
+			return
+
+		case <-lndInactive:
+			Logger.Info("lnd is unavailable, stopping monitors")
+			p.stopMonitors()
+			running = false
+
+		case <-lndActive:
+			// Errors from while lnd was unavailable may still be
+			// pending, which we don't want to consider fatal.
+			for len(p.errChan) > 0 {
+				<-p.errChan
+			}
+
+			Logger.Info("lnd is active again, restarting monitors")
+			if err := p.startMonitors(); err != nil {
+				if p.lndUnavailable() {
+					Logger.Infof("Unable to restart "+
+						"monitors: %v", err)
+					continue
+				}
+
+				p.errors <- err
+				return
+			}
+			running = true
+
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// lndUnavailable waits up to our grace period for the wallet state monitor to
+// notice that lnd is unavailable. lnd fails our requests as soon as it shuts
+// down, which may be shortly before its state subscription fails.
+func (p *PrometheusExporter) lndUnavailable() bool {
+	select {
+	case <-p.walletStateMonitor.lndInactive():
+		return true
+
+	case <-time.After(p.lndGracePeriod):
+		return false
+
+	case <-p.quit:
+		return true
+	}
+}
+
+// gossipEnabled returns true if the gossip monitor should be running. Since
+// the gossip monitor backs the graph collector, it is disabled together with
+// the graph metrics.
+func (p *PrometheusExporter) gossipEnabled() bool {
+	return !p.monitoringCfg.DisableGraph && !p.monitoringCfg.DisableGossip
 }
 
 // Errors returns an error channel that any failures experienced by its
-// collectors experience.
+// collectors experience. If we know lnd's state, failures caused by lnd being
+// unavailable are not reported.
 func (p *PrometheusExporter) Errors() <-chan error {
-	return p.errChan
+	if p.walletStateMonitor == nil {
+		return p.errChan
+	}
+
+	return p.errors
 }
 
 // registerMetrics iterates through all the registered collectors and attempts
@@ -0,0 +1,142 @@
+package collectors
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+// metricSeries is a collected series of a gauge or counter.
+type metricSeries struct {
+	labels map[string]string
+	value  float64
+}
+
+// collectMetrics reads all metrics from the given channel, which must be
+// closed, and groups their series by descriptor.
+func collectMetrics(t *testing.T,
+	ch <-chan prometheus.Metric) map[*prometheus.Desc][]metricSeries {
+
+	t.Helper()
+
+	series := make(map[*prometheus.Desc][]metricSeries)
+	for metric := range ch {
+		var m dto.Metric
+		require.NoError(t, metric.Write(&m))
+
+		labels := make(map[string]string, len(m.GetLabel()))
+		for _, label := range m.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+
+		value := m.GetGauge().GetValue()
+		if m.Counter != nil {
+			value = m.GetCounter().GetValue()
+		}
+
+		desc := metric.Desc()
+		series[desc] = append(series[desc], metricSeries{
+			labels: labels,
+			value:  value,
+		})
+	}
+
+	return series
+}
+
+// valuesByLabel returns the values of the given series, keyed by their value
+// of the given label.
+func valuesByLabel(series []metricSeries, label string) map[string]float64 {
+	values := make(map[string]float64, len(series))
+	for _, s := range series {
+		values[s.labels[label]] = s.value
+	}
+
+	return values
+}
+
+// TestSendError tests that monitors don't block on a full error channel once
+// they are stopped.
+func TestSendError(t *testing.T) {
+	errChan := make(chan error, 1)
+	quit := make(chan struct{})
+
+	sendError(errChan, quit, errors.New("first"))
+
+	close(quit)
+	sendError(errChan, quit, errors.New("second"))
+
+	require.EqualError(t, <-errChan, "first")
+}
+
+// TestSuperviseMonitors tests that errors are only reported if lnd is still
+// active after our grace period.
+func TestSuperviseMonitors(t *testing.T) {
+	walletStateMonitor, streams := newFakeWalletStateMonitor()
+	require.NoError(t, walletStateMonitor.start())
+	defer walletStateMonitor.stop()
+
+	stream := <-streams
+	stream.states <- lnrpc.WalletState_SERVER_ACTIVE
+	requireClosed(t, walletStateMonitor.lndActive())
+
+	errChan := make(chan error, errChanBufferSize)
+	exporter := &PrometheusExporter{
+		monitoringCfg: &MonitoringConfig{
+			DisableHtlc:          true,
+			DisablePayments:      true,
+			DisableGraph:         true,
+			DisablePolicyUpdates: true,
+			DisablePeerEvents:    true,
+		},
+		walletStateMonitor: walletStateMonitor,
+		lndGracePeriod:     time.Second,
+		errChan:            errChan,
+		errors:             make(chan error, 1),
+		quit:               make(chan struct{}),
+	}
+
+	exporter.wg.Add(1)
+	go exporter.superviseMonitors()
+	defer func() {
+		close(exporter.quit)
+		exporter.wg.Wait()
+	}()
+
+	// Errors caused by lnd shutting down are ignored, including those
+	// that arrive while lnd is unavailable.
+	errChan <- errors.New("stream failed")
+	close(stream.states)
+	requireClosed(t, walletStateMonitor.lndInactive())
+	errChan <- errors.New("scrape failed")
+
+	// Once lnd is back, errors are reported again. We keep sending them,
+	// since errors that arrive before our monitors are restarted are
+	// still ignored.
+	stream = <-streams
+	stream.states <- lnrpc.WalletState_SERVER_ACTIVE
+	requireClosed(t, walletStateMonitor.lndActive())
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case err := <-exporter.Errors():
+			require.EqualError(t, err, "fatal")
+			return
+
+		case <-ticker.C:
+			errChan <- errors.New("fatal")
+
+		case <-timeout:
+			t.Fatal("error not reported")
+		}
+	}
+}
@@ -78,3 +78,21 @@ func (s *statsCompiler) Report() statsReport {
 
 	return s.statsReport
 }
+
+// Percentile returns the given percentile (0-100) of the data set, linearly
+// interpolating between the closest ranks. This should only be called after
+// Report(), which sorts the samples.
+func (s *statsCompiler) Percentile(percentile float64) float64 {
+	num := len(s.samples)
+	if num == 0 {
+		return 0
+	}
+
+	rank := percentile / 100 * float64(num-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+
+	weight := rank - float64(lower)
+
+	return s.samples[lower]*(1-weight) + s.samples[upper]*weight
+}
@@ -0,0 +1,187 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnrpc/walletrpc"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SweepsCollector is a collector that exports metrics about the outputs that
+// lnd's sweeper is currently trying to sweep, such as force closed channel
+// outputs and anchors.
+type SweepsCollector struct {
+	lnd *lndclient.LndServices
+
+	// The descriptors below describe all pending sweeps of a witness
+	// type.
+	pendingSweepsDesc     *prometheus.Desc
+	pendingSweepValueDesc *prometheus.Desc
+
+	// The descriptors below describe individual pending sweeps.
+	feeRateDesc           *prometheus.Desc
+	requestedFeeRateDesc  *prometheus.Desc
+	broadcastAttemptsDesc *prometheus.Desc
+	deadlineDeltaDesc     *prometheus.Desc
+	budgetDesc            *prometheus.Desc
+
+	// errChan is a channel that we send any errors that we encounter into.
+	// This channel should be buffered so that it does not block sends.
+	errChan chan<- error
+}
+
+// NewSweepsCollector returns a new instance of the SweepsCollector.
+func NewSweepsCollector(lnd *lndclient.LndServices,
+	errChan chan<- error) *SweepsCollector {
+
+	sweepLabels := []string{"outpoint", "witness_type"}
+
+	return &SweepsCollector{
+		lnd: lnd,
+		pendingSweepsDesc: prometheus.NewDesc(
+			"lnd_sweep_pending_count",
+			"number of pending sweeps per witness type",
+			[]string{"witness_type"}, nil,
+		),
+		pendingSweepValueDesc: prometheus.NewDesc(
+			"lnd_sweep_pending_value_sat",
+			"total value of pending sweeps per witness type",
+			[]string{"witness_type"}, nil,
+		),
+		feeRateDesc: prometheus.NewDesc(
+			"lnd_sweep_fee_rate_sat_per_vbyte",
+			"current fee rate of the sweep, 0 before it was "+
+				"first broadcast",
+			sweepLabels, nil,
+		),
+		requestedFeeRateDesc: prometheus.NewDesc(
+			"lnd_sweep_requested_fee_rate_sat_per_vbyte",
+			"requested starting fee rate of the sweep, 0 if none "+
+				"was requested",
+			sweepLabels, nil,
+		),
+		broadcastAttemptsDesc: prometheus.NewDesc(
+			"lnd_sweep_broadcast_attempts",
+			"number of times we attempted to broadcast the sweep",
+			sweepLabels, nil,
+		),
+		deadlineDeltaDesc: prometheus.NewDesc(
+			"lnd_sweep_blocks_until_deadline",
+			"number of blocks left until the deadline of the "+
+				"sweep, negative if it was missed",
+			sweepLabels, nil,
+		),
+		budgetDesc: prometheus.NewDesc(
+			"lnd_sweep_budget_sat",
+			"maximum amount of fees the sweep may spend",
+			sweepLabels, nil,
+		),
+		errChan: errChan,
+	}
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once the
+// last descriptor has been sent.
+//
+// NOTE: Part of the prometheus.Collector interface.
+func (s *SweepsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.pendingSweepsDesc
+	ch <- s.pendingSweepValueDesc
+	ch <- s.feeRateDesc
+	ch <- s.requestedFeeRateDesc
+	ch <- s.broadcastAttemptsDesc
+	ch <- s.deadlineDeltaDesc
+	ch <- s.budgetDesc
+}
+
+// Collect is called by the Prometheus registry when collecting metrics.
+//
+// NOTE: Part of the prometheus.Collector interface.
+func (s *SweepsCollector) Collect(ch chan<- prometheus.Metric) {
+	// lndclient doesn't expose pending sweeps, so we use the raw client.
+	rpcCtx, timeout, client := s.lnd.WalletKit.RawClientWithMacAuth(
+		context.Background(),
+	)
+	rpcCtx, cancel := context.WithTimeout(rpcCtx, timeout)
+	defer cancel()
+
+	resp, err := client.PendingSweeps(
+		rpcCtx, &walletrpc.PendingSweepsRequest{},
+	)
+	if err != nil {
+		s.errChan <- fmt.Errorf("SweepsCollector PendingSweeps failed "+
+			"with: %v", err)
+		return
+	}
+
+	info, err := s.lnd.Client.GetInfo(context.Background())
+	if err != nil {
+		s.errChan <- fmt.Errorf("SweepsCollector GetInfo failed with: "+
+			"%v", err)
+		return
+	}
+
+	s.collectSweeps(ch, resp.PendingSweeps, int64(info.BlockHeight))
+}
+
+// collectSweeps exports the metrics of the given pending sweeps at the given
+// block height.
+func (s *SweepsCollector) collectSweeps(ch chan<- prometheus.Metric,
+	sweeps []*walletrpc.PendingSweep, height int64) {
+
+	var (
+		counts = make(map[string]int)
+		values = make(map[string]float64)
+	)
+	for _, sweep := range sweeps {
+		witnessType := strings.ToLower(sweep.WitnessType.String())
+
+		counts[witnessType]++
+		values[witnessType] += float64(sweep.AmountSat)
+
+		var outpoint string
+		if sweep.Outpoint != nil {
+			outpoint = fmt.Sprintf("%v:%d", sweep.Outpoint.TxidStr,
+				sweep.Outpoint.OutputIndex)
+		}
+
+		gauge := func(desc *prometheus.Desc, value float64) {
+			ch <- prometheus.MustNewConstMetric(
+				desc, prometheus.GaugeValue, value, outpoint,
+				witnessType,
+			)
+		}
+
+		gauge(s.feeRateDesc, float64(sweep.SatPerVbyte))
+		gauge(
+			s.requestedFeeRateDesc,
+			float64(sweep.RequestedSatPerVbyte),
+		)
+		gauge(s.broadcastAttemptsDesc, float64(sweep.BroadcastAttempts))
+		gauge(s.budgetDesc, float64(sweep.Budget))
+
+		// Sweeps without a deadline don't need to confirm by any
+		// particular height.
+		if sweep.DeadlineHeight != 0 {
+			gauge(
+				s.deadlineDeltaDesc,
+				float64(int64(sweep.DeadlineHeight)-height),
+			)
+		}
+	}
+
+	for witnessType, count := range counts {
+		ch <- prometheus.MustNewConstMetric(
+			s.pendingSweepsDesc, prometheus.GaugeValue,
+			float64(count), witnessType,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			s.pendingSweepValueDesc, prometheus.GaugeValue,
+			values[witnessType], witnessType,
+		)
+	}
+}
@@ -0,0 +1,64 @@
+package collectors
+
+import (
+	"testing"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/walletrpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSweepsCollector tests that we group pending sweeps by witness type and
+// report how many blocks are left until their deadline.
+func TestSweepsCollector(t *testing.T) {
+	collector := NewSweepsCollector(nil, make(chan error, 1))
+
+	ch := make(chan prometheus.Metric, 20)
+	collector.collectSweeps(ch, []*walletrpc.PendingSweep{
+		{
+			Outpoint: &lnrpc.OutPoint{
+				TxidStr: "aa", OutputIndex: 1,
+			},
+			WitnessType:    walletrpc.WitnessType_COMMITMENT_ANCHOR,
+			AmountSat:      330,
+			DeadlineHeight: 110,
+		},
+		{
+			Outpoint: &lnrpc.OutPoint{
+				TxidStr: "bb", OutputIndex: 0,
+			},
+			WitnessType:    walletrpc.WitnessType_COMMITMENT_ANCHOR,
+			AmountSat:      330,
+			DeadlineHeight: 95,
+		},
+		{
+			Outpoint: &lnrpc.OutPoint{
+				TxidStr: "cc", OutputIndex: 2,
+			},
+			WitnessType: walletrpc.WitnessType_COMMITMENT_TIME_LOCK,
+			AmountSat:   100_000,
+		},
+	}, 100)
+	close(ch)
+
+	series := collectMetrics(t, ch)
+
+	require.Equal(t, map[string]float64{
+		"commitment_anchor":    2,
+		"commitment_time_lock": 1,
+	}, valuesByLabel(series[collector.pendingSweepsDesc], "witness_type"))
+	require.Equal(t, map[string]float64{
+		"commitment_anchor":    660,
+		"commitment_time_lock": 100_000,
+	}, valuesByLabel(
+		series[collector.pendingSweepValueDesc], "witness_type",
+	))
+
+	// The sweep without a deadline has no deadline delta.
+	require.Equal(t, map[string]float64{
+		"aa:1": 10,
+		"bb:0": -5,
+	}, valuesByLabel(series[collector.deadlineDeltaDesc], "outpoint"))
+	require.Len(t, series[collector.budgetDesc], 3)
+}
@@ -0,0 +1,363 @@
+package collectors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/labels"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	txCategoryChannelOpen  = "channel_open"
+	txCategoryChannelClose = "channel_close"
+	txCategorySweep        = "sweep"
+	txCategorySend         = "send"
+	txCategoryReceive      = "receive"
+	txCategoryOther        = "other"
+
+	// txCursorFilePrefix is the prefix of the file we persist our
+	// transaction cursor in. It is suffixed with lnd's network.
+	txCursorFilePrefix = "txcursor"
+)
+
+// txCategory returns the category of an on-chain transaction. Transactions
+// that lnd published itself are categorized by their label, all others by
+// whether they sent or received funds.
+func txCategory(tx *lndclient.Transaction) string {
+	parts := strings.Split(tx.Label, ":")
+	if len(parts) >= 2 &&
+		parts[0] == fmt.Sprint(labels.LabelVersionZero) {
+
+		switch labels.LabelType(parts[1]) {
+		case labels.LabelTypeChannelOpen:
+			return txCategoryChannelOpen
+
+		case labels.LabelTypeChannelClose:
+			return txCategoryChannelClose
+
+		case labels.LabelTypeSweepTransaction,
+			labels.LabelTypeJusticeTransaction:
+
+			return txCategorySweep
+		}
+	}
+
+	switch {
+	case tx.Amount < 0:
+		return txCategorySend
+
+	case tx.Amount > 0:
+		return txCategoryReceive
+
+	default:
+		return txCategoryOther
+	}
+}
+
+// txCursor records how far we've processed the transactions of our wallet.
+type txCursor struct {
+	// Pubkey is the pubkey of the node the cursor belongs to.
+	Pubkey string `json:"pubkey"`
+
+	// Height is the height of the last block whose transactions we've
+	// processed.
+	Height int32 `json:"height"`
+
+	// Pending maps the hashes of unconfirmed transactions to the unix
+	// timestamp at which our wallet first saw them.
+	Pending map[string]int64 `json:"pending"`
+}
+
+// TransactionsCollector is a collector that exports metrics about the
+// on-chain transactions of lnd's wallet. It processes transactions
+// incrementally by block height, and persists how far it got so that
+// transactions that confirmed while lndmon wasn't running are still counted
+// once it starts up again.
+type TransactionsCollector struct {
+	lnd lndclient.LightningClient
+
+	// cursorFile is the file we persist our cursor in. If empty, the
+	// cursor is only kept in memory.
+	cursorFile string
+
+	// cursor is the cursor of the transactions we've processed so far, or
+	// nil before we initialized it. It is guarded by cursorMtx.
+	cursor    *txCursor
+	cursorMtx sync.Mutex
+
+	txCounter  *prometheus.CounterVec
+	feeCounter *prometheus.CounterVec
+	confDelay  *prometheus.HistogramVec
+
+	cursorHeightDesc *prometheus.Desc
+
+	// errChan is a channel that we send any errors that we encounter into.
+	// This channel should be buffered so that it does not block sends.
+	errChan chan<- error
+}
+
+// NewTransactionsCollector returns a new instance of the
+// TransactionsCollector. If dataDir is empty, the cursor is not persisted.
+func NewTransactionsCollector(lnd lndclient.LightningClient, dataDir string,
+	network string, errChan chan<- error) *TransactionsCollector {
+
+	var cursorFile string
+	if dataDir != "" {
+		cursorFile = filepath.Join(
+			dataDir, fmt.Sprintf("%v-%v.json", txCursorFilePrefix,
+				network),
+		)
+	}
+
+	categoryLabels := []string{"category"}
+
+	return &TransactionsCollector{
+		lnd:        lnd,
+		cursorFile: cursorFile,
+		txCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "lnd",
+				Subsystem: "chain_tx",
+				Name:      "total",
+				Help: "number of confirmed on-chain " +
+					"transactions of our wallet",
+			}, categoryLabels,
+		),
+		feeCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "lnd",
+				Subsystem: "chain_tx",
+				Name:      "fees_sat_total",
+				Help: "on-chain fees our wallet paid for " +
+					"confirmed transactions",
+			}, categoryLabels,
+		),
+		confDelay: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: "lnd",
+				Subsystem: "chain_tx",
+				Name:      "confirmation_delay_seconds",
+				Help: "time from our wallet first seeing a " +
+					"transaction until it confirmed",
+				Buckets: prometheus.ExponentialBuckets(
+					60, 2, 12,
+				),
+			}, categoryLabels,
+		),
+		cursorHeightDesc: prometheus.NewDesc(
+			"lnd_chain_tx_cursor_height",
+			"height up to which on-chain transactions were "+
+				"processed",
+			nil, nil,
+		),
+		errChan: errChan,
+	}
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once the
+// last descriptor has been sent.
+//
+// NOTE: Part of the prometheus.Collector interface.
+func (t *TransactionsCollector) Describe(ch chan<- *prometheus.Desc) {
+	t.txCounter.Describe(ch)
+	t.feeCounter.Describe(ch)
+	t.confDelay.Describe(ch)
+	ch <- t.cursorHeightDesc
+}
+
+// Collect is called by the Prometheus registry when collecting metrics.
+//
+// NOTE: Part of the prometheus.Collector interface.
+func (t *TransactionsCollector) Collect(ch chan<- prometheus.Metric) {
+	t.cursorMtx.Lock()
+	defer t.cursorMtx.Unlock()
+
+	if err := t.update(context.Background()); err != nil {
+		t.errChan <- fmt.Errorf("TransactionsCollector update failed "+
+			"with: %v", err)
+		return
+	}
+
+	t.txCounter.Collect(ch)
+	t.feeCounter.Collect(ch)
+	t.confDelay.Collect(ch)
+
+	ch <- prometheus.MustNewConstMetric(
+		t.cursorHeightDesc, prometheus.GaugeValue,
+		float64(t.cursor.Height),
+	)
+}
+
+// update processes all transactions since our cursor and persists the
+// updated cursor. The caller must hold cursorMtx.
+func (t *TransactionsCollector) update(ctx context.Context) error {
+	if t.cursor == nil {
+		cursor, err := t.initCursor(ctx)
+		if err != nil {
+			return err
+		}
+		t.cursor = cursor
+	}
+
+	// An end height of -1 includes our unconfirmed transactions, which we
+	// need to time how long they take to confirm.
+	txs, err := t.lnd.ListTransactions(ctx, t.cursor.Height+1, -1)
+	if err != nil {
+		return err
+	}
+
+	if !t.processTransactions(txs) {
+		return nil
+	}
+
+	return t.saveCursor()
+}
+
+// processTransactions accounts for the given transactions, which must include
+// all of our unconfirmed transactions, and advances our cursor past the
+// confirmed ones. It returns true if the cursor changed.
+func (t *TransactionsCollector) processTransactions(
+	txs []lndclient.Transaction) bool {
+
+	var (
+		changed bool
+		pending = make(map[string]int64)
+	)
+	for i := range txs {
+		tx := &txs[i]
+
+		// Unconfirmed transactions are timestamped with the time our
+		// wallet first saw them.
+		if tx.BlockHeight <= 0 {
+			firstSeen, ok := t.cursor.Pending[tx.TxHash]
+			if !ok {
+				firstSeen = tx.Timestamp.Unix()
+				changed = true
+			}
+			pending[tx.TxHash] = firstSeen
+
+			continue
+		}
+
+		// Confirmed ones are timestamped with the time of the block
+		// they confirmed in.
+		category := txCategory(tx)
+		t.txCounter.WithLabelValues(category).Inc()
+		t.feeCounter.WithLabelValues(category).Add(float64(tx.Fee))
+
+		if firstSeen, ok := t.cursor.Pending[tx.TxHash]; ok {
+			delay := tx.Timestamp.Sub(time.Unix(firstSeen, 0))
+			if delay < 0 {
+				delay = 0
+			}
+
+			t.confDelay.WithLabelValues(category).Observe(
+				delay.Seconds(),
+			)
+		}
+
+		if tx.BlockHeight > t.cursor.Height {
+			t.cursor.Height = tx.BlockHeight
+		}
+		changed = true
+	}
+
+	// Transactions that are neither pending nor confirmed anymore were
+	// replaced or abandoned, so we forget them.
+	if len(pending) != len(t.cursor.Pending) {
+		changed = true
+	}
+	t.cursor.Pending = pending
+
+	return changed
+}
+
+// initCursor loads our persisted cursor. If we don't have one for the node
+// we're connected to, we start at the current block height rather than
+// processing the full history of the wallet at once.
+func (t *TransactionsCollector) initCursor(ctx context.Context) (*txCursor,
+	error) {
+
+	info, err := t.lnd.GetInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pubkey := route.Vertex(info.IdentityPubkey).String()
+
+	cursor, err := t.loadCursor()
+	switch {
+	case err != nil:
+		return nil, err
+
+	case cursor != nil && cursor.Pubkey == pubkey:
+		if cursor.Pending == nil {
+			cursor.Pending = make(map[string]int64)
+		}
+
+		return cursor, nil
+	}
+
+	return &txCursor{
+		Pubkey:  pubkey,
+		Height:  int32(info.BlockHeight),
+		Pending: make(map[string]int64),
+	}, nil
+}
+
+// loadCursor reads our persisted cursor. It returns nil if it doesn't exist.
+func (t *TransactionsCollector) loadCursor() (*txCursor, error) {
+	if t.cursorFile == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(t.cursorFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor txCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, fmt.Errorf("invalid transaction cursor %v: %v",
+			t.cursorFile, err)
+	}
+
+	return &cursor, nil
+}
+
+// saveCursor persists our cursor. We write it to a temporary file first, so
+// that we never leave a partially written cursor behind.
+func (t *TransactionsCollector) saveCursor() error {
+	if t.cursorFile == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(t.cursor)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(t.cursorFile), 0700); err != nil {
+		return err
+	}
+
+	tmpFile := t.cursorFile + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile, t.cursorFile)
+}
@@ -0,0 +1,107 @@
+package collectors
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTransactionsCollector tests that we categorize confirmed transactions,
+// time how long our pending ones take to confirm and persist our cursor.
+func TestTransactionsCollector(t *testing.T) {
+	dataDir := t.TempDir()
+	collector := NewTransactionsCollector(
+		nil, dataDir, "regtest", make(chan error, 1),
+	)
+	require.Equal(
+		t, filepath.Join(dataDir, "txcursor-regtest.json"),
+		collector.cursorFile,
+	)
+
+	collector.cursor = &txCursor{
+		Pubkey:  "pubkey",
+		Height:  100,
+		Pending: make(map[string]int64),
+	}
+
+	firstSeen := time.Unix(1_000_000, 0)
+	changed := collector.processTransactions([]lndclient.Transaction{
+		{
+			TxHash:    "open",
+			Timestamp: firstSeen,
+			Amount:    -100_000,
+			Fee:       500,
+			Label:     "0:openchannel:shortchanid-123",
+		},
+		{
+			TxHash:    "abandoned",
+			Timestamp: firstSeen,
+			Amount:    -1000,
+		},
+	})
+	require.True(t, changed)
+	require.Len(t, collector.cursor.Pending, 2)
+	require.NoError(t, collector.saveCursor())
+
+	// The next time around, the channel open confirmed and the other
+	// transaction disappeared.
+	changed = collector.processTransactions([]lndclient.Transaction{
+		{
+			TxHash:      "open",
+			Timestamp:   firstSeen.Add(10 * time.Minute),
+			Amount:      -100_000,
+			Fee:         500,
+			Label:       "0:openchannel:shortchanid-123",
+			BlockHeight: 102,
+		},
+		{
+			TxHash:      "sweep",
+			Timestamp:   firstSeen.Add(10 * time.Minute),
+			Fee:         300,
+			Label:       "0:sweep",
+			BlockHeight: 101,
+		},
+		{
+			TxHash:      "deposit",
+			Timestamp:   firstSeen.Add(20 * time.Minute),
+			Amount:      50_000,
+			BlockHeight: 101,
+		},
+	})
+	require.True(t, changed)
+	require.EqualValues(t, 102, collector.cursor.Height)
+	require.Empty(t, collector.cursor.Pending)
+
+	require.Equal(t, 500.0, testutil.ToFloat64(
+		collector.feeCounter.WithLabelValues(txCategoryChannelOpen),
+	))
+	require.Equal(t, 300.0, testutil.ToFloat64(
+		collector.feeCounter.WithLabelValues(txCategorySweep),
+	))
+	require.Equal(t, 1.0, testutil.ToFloat64(
+		collector.txCounter.WithLabelValues(txCategoryReceive),
+	))
+
+	// Only the channel open was seen unconfirmed, so only its delay is
+	// known.
+	require.Equal(t, 1, testutil.CollectAndCount(collector.confDelay))
+
+	// Nothing new happened, so the cursor doesn't need to be persisted.
+	require.False(t, collector.processTransactions(nil))
+
+	// The cursor we persisted before is loaded again.
+	cursor, err := collector.loadCursor()
+	require.NoError(t, err)
+	require.Equal(t, &txCursor{
+		Pubkey: "pubkey",
+		Height: 100,
+		Pending: map[string]int64{
+			"open":      firstSeen.Unix(),
+			"abandoned": firstSeen.Unix(),
+		},
+	}, cursor)
+}
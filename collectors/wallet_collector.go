@@ -7,9 +7,74 @@ import (
 
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnwallet"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+const (
+	// Define the names and help texts of our UTXO histograms.
+	utxoSizeName = "lnd_utxos_size_sat_histogram"
+	utxoSizeHelp = "histogram of UTXO sizes in sat"
+	utxoAgeName  = "lnd_utxos_age_confs_histogram"
+	utxoAgeHelp  = "histogram of UTXO ages in confirmations"
+)
+
+var (
+	// utxoSizeBuckets are the buckets of our UTXO size histogram, from
+	// 1000 sat to about 42 BTC.
+	utxoSizeBuckets = prometheus.ExponentialBuckets(1000, 4, 12)
+
+	// utxoAgeBuckets are the buckets of our UTXO age histogram, ranging
+	// from unconfirmed to a year.
+	utxoAgeBuckets = []float64{0, 1, 3, 6, 144, 1008, 4320, 52560}
+)
+
+// utxoAddressType returns the label we use for the given UTXO address type.
+func utxoAddressType(addrType lnwallet.AddressType) string {
+	switch addrType {
+	case lnwallet.WitnessPubKey:
+		return "p2wkh"
+
+	case lnwallet.NestedWitnessPubKey:
+		return "np2wkh"
+
+	case lnwallet.TaprootPubkey:
+		return "p2tr"
+
+	default:
+		return "unknown"
+	}
+}
+
+// utxoGroup is the number and total value of a group of UTXOs.
+type utxoGroup struct {
+	count int
+	value btcutil.Amount
+}
+
+// add adds a UTXO to the group.
+func (g *utxoGroup) add(utxo *lnwallet.Utxo) {
+	g.count++
+	g.value += utxo.Value
+}
+
+// utxosByAddressType groups UTXOs by their address type.
+func utxosByAddressType(utxos []*lnwallet.Utxo) map[string]*utxoGroup {
+	groups := make(map[string]*utxoGroup)
+	for _, utxo := range utxos {
+		addrType := utxoAddressType(utxo.AddressType)
+
+		group, ok := groups[addrType]
+		if !ok {
+			group = &utxoGroup{}
+			groups[addrType] = group
+		}
+		group.add(utxo)
+	}
+
+	return groups
+}
+
 // WalletCollector is a collector that will export metrics related to lnd's
 // on-chain wallet. .
 type WalletCollector struct {
@@ -25,6 +90,16 @@ type WalletCollector struct {
 	maxUtxoSizeDesc *prometheus.Desc
 	avgUtxoSizeDesc *prometheus.Desc
 
+	// We'll break our UTXOs down by address type and account.
+	addrTypeUtxosDesc     *prometheus.Desc
+	addrTypeUtxoValueDesc *prometheus.Desc
+	accountUtxosDesc      *prometheus.Desc
+	accountUtxoValueDesc  *prometheus.Desc
+
+	// Histograms of the sizes and ages of our UTXOs.
+	utxoSizeDesc *prometheus.Desc
+	utxoAgeDesc  *prometheus.Desc
+
 	// Three gauges will be used to keep track of the confirmed, unconfirmed
 	// and total balances in the wallet.
 	confirmedBalanceDesc   *prometheus.Desc
@@ -65,6 +140,32 @@ func NewWalletCollector(lnd *lndclient.LndServices,
 			"lnd_utxos_sizes_avg_sat", "average UTXO size",
 			nil, nil,
 		),
+		addrTypeUtxosDesc: prometheus.NewDesc(
+			"lnd_utxos_address_type_count",
+			"number of UTXOs per address type",
+			[]string{"address_type"}, nil,
+		),
+		addrTypeUtxoValueDesc: prometheus.NewDesc(
+			"lnd_utxos_address_type_value_sat",
+			"total value of UTXOs per address type",
+			[]string{"address_type"}, nil,
+		),
+		accountUtxosDesc: prometheus.NewDesc(
+			"lnd_utxos_account_count",
+			"number of UTXOs per account",
+			[]string{"account_name"}, nil,
+		),
+		accountUtxoValueDesc: prometheus.NewDesc(
+			"lnd_utxos_account_value_sat",
+			"total value of UTXOs per account",
+			[]string{"account_name"}, nil,
+		),
+		utxoSizeDesc: prometheus.NewDesc(
+			utxoSizeName, utxoSizeHelp, nil, nil,
+		),
+		utxoAgeDesc: prometheus.NewDesc(
+			utxoAgeName, utxoAgeHelp, nil, nil,
+		),
 		confirmedBalanceDesc: prometheus.NewDesc(
 			"lnd_wallet_balance_confirmed_sat",
 			"confirmed wallet balance",
@@ -100,6 +201,12 @@ func (u *WalletCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- u.minUtxoSizeDesc
 	ch <- u.maxUtxoSizeDesc
 	ch <- u.avgUtxoSizeDesc
+	ch <- u.addrTypeUtxosDesc
+	ch <- u.addrTypeUtxoValueDesc
+	ch <- u.accountUtxosDesc
+	ch <- u.accountUtxoValueDesc
+	ch <- u.utxoSizeDesc
+	ch <- u.utxoAgeDesc
 	ch <- u.confirmedBalanceDesc
 	ch <- u.unconfirmedBalanceDesc
 	ch <- u.keyCountDesc
@@ -123,6 +230,21 @@ func (u *WalletCollector) Collect(ch chan<- prometheus.Metric) {
 	var (
 		numConf, numUnconf  uint32
 		sum, maxAmt, minAmt btcutil.Amount
+
+		sizeHistogram = prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    utxoSizeName,
+				Help:    utxoSizeHelp,
+				Buckets: utxoSizeBuckets,
+			},
+		)
+		ageHistogram = prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    utxoAgeName,
+				Help:    utxoAgeHelp,
+				Buckets: utxoAgeBuckets,
+			},
+		)
 	)
 
 	// For each UTXO, we'll count the tally of confirmed vs unconfirmed,
@@ -130,6 +252,9 @@ func (u *WalletCollector) Collect(ch chan<- prometheus.Metric) {
 	for _, utxo := range utxos {
 		sum += utxo.Value
 
+		sizeHistogram.Observe(float64(utxo.Value))
+		ageHistogram.Observe(float64(utxo.Confirmations))
+
 		switch utxo.Confirmations {
 		case 0:
 			numUnconf++
@@ -145,7 +270,12 @@ func (u *WalletCollector) Collect(ch chan<- prometheus.Metric) {
 		}
 	}
 
-	avg := float64(sum) / float64(numConf+numUnconf)
+	// An empty wallet has no average UTXO size, so we report zero like we
+	// do for the min and max size.
+	var avg float64
+	if numUtxos := numConf + numUnconf; numUtxos > 0 {
+		avg = float64(sum) / float64(numUtxos)
+	}
 
 	ch <- prometheus.MustNewConstMetric(
 		u.numUtxosConfDesc, prometheus.GaugeValue, float64(numConf),
@@ -167,6 +297,20 @@ func (u *WalletCollector) Collect(ch chan<- prometheus.Metric) {
 		u.avgUtxoSizeDesc, prometheus.GaugeValue, avg,
 	)
 
+	sizeHistogram.Collect(ch)
+	ageHistogram.Collect(ch)
+
+	for addrType, group := range utxosByAddressType(utxos) {
+		ch <- prometheus.MustNewConstMetric(
+			u.addrTypeUtxosDesc, prometheus.GaugeValue,
+			float64(group.count), addrType,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			u.addrTypeUtxoValueDesc, prometheus.GaugeValue,
+			float64(group.value), addrType,
+		)
+	}
+
 	// Next, we'll query the wallet to determine our confirmed and unconf
 	// balance at this instance.
 	walletBal, err := u.lnd.Client.WalletBalance(context.Background())
@@ -192,6 +336,40 @@ func (u *WalletCollector) Collect(ch chan<- prometheus.Metric) {
 		return
 	}
 
+	// UTXOs don't tell us which account they belong to, so we need to
+	// query them per account. Accounts of different address types can
+	// share a name, in which case we only query them once.
+	accountNames := make(map[string]struct{})
+	for _, account := range accounts {
+		accountNames[account.GetName()] = struct{}{}
+	}
+
+	for name := range accountNames {
+		utxos, err := u.lnd.WalletKit.ListUnspent(
+			context.Background(), 0, math.MaxInt32,
+			lndclient.WithUnspentAccount(name),
+		)
+		if err != nil {
+			u.errChan <- fmt.Errorf("WalletCollector ListUnspent "+
+				"for account %v failed with: %v", name, err)
+			return
+		}
+
+		var group utxoGroup
+		for _, utxo := range utxos {
+			group.add(utxo)
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			u.accountUtxosDesc, prometheus.GaugeValue,
+			float64(group.count), name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			u.accountUtxoValueDesc, prometheus.GaugeValue,
+			float64(group.value), name,
+		)
+	}
+
 	for _, account := range accounts {
 		name := account.GetName()
 		addrType := account.GetAddressType().String()
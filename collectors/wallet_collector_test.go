@@ -0,0 +1,28 @@
+package collectors
+
+import (
+	"testing"
+
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUtxosByAddressType tests that we group UTXOs by their address type.
+func TestUtxosByAddressType(t *testing.T) {
+	groups := utxosByAddressType([]*lnwallet.Utxo{
+		{AddressType: lnwallet.WitnessPubKey, Value: 100},
+		{AddressType: lnwallet.TaprootPubkey, Value: 200},
+		{AddressType: lnwallet.WitnessPubKey, Value: 300},
+		{AddressType: lnwallet.NestedWitnessPubKey, Value: 400},
+		{AddressType: lnwallet.UnknownAddressType, Value: 500},
+	})
+
+	require.Equal(t, map[string]*utxoGroup{
+		"p2wkh":   {count: 2, value: 400},
+		"np2wkh":  {count: 1, value: 400},
+		"p2tr":    {count: 1, value: 200},
+		"unknown": {count: 1, value: 500},
+	}, groups)
+
+	require.Empty(t, utxosByAddressType(nil))
+}
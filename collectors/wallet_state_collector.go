@@ -0,0 +1,526 @@
+package collectors
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// walletStateUnreachable is the state we report while we're not
+	// connected to lnd.
+	walletStateUnreachable = "unreachable"
+
+	// stateResubscribeInterval is the interval at which we try to
+	// resubscribe to lnd's state after losing our connection to it.
+	stateResubscribeInterval = time.Second
+)
+
+// errShuttingDown is returned when we try to subscribe to lnd's state while the
+// wallet state monitor is shutting down.
+var errShuttingDown = errors.New("wallet state monitor shutting down")
+
+// walletStates are the states we export, in the order lnd goes through them
+// during startup.
+var walletStates = []string{
+	walletStateUnreachable,
+	walletStateLabel(lnrpc.WalletState_WAITING_TO_START),
+	walletStateLabel(lnrpc.WalletState_NON_EXISTING),
+	walletStateLabel(lnrpc.WalletState_LOCKED),
+	walletStateLabel(lnrpc.WalletState_UNLOCKED),
+	walletStateLabel(lnrpc.WalletState_RPC_ACTIVE),
+	walletStateLabel(lnrpc.WalletState_SERVER_ACTIVE),
+}
+
+// walletStateLabel returns the label we report the given state under.
+func walletStateLabel(state lnrpc.WalletState) string {
+	return strings.ToLower(state.String())
+}
+
+// stateSubscriber opens a new subscription to lnd's wallet state.
+type stateSubscriber func(ctx context.Context) (
+	lnrpc.State_SubscribeStateClient, error)
+
+// walletStateMonitor subscribes to lnd's wallet state for as long as lndmon
+// is running. It keeps listening once lnd's server is active and resubscribes
+// if lnd goes away, so that it sees every startup of lnd. The exporter uses it
+// to tell whether lnd is available.
+type walletStateMonitor struct {
+	// subscribeState opens our subscriptions to lnd's wallet state.
+	subscribeState stateSubscriber
+
+	// resubscribeInterval is the interval at which we try to resubscribe
+	// to lnd's state after losing our connection to it.
+	resubscribeInterval time.Duration
+
+	// programStartTime is a best-effort timestamp of when lndmon was
+	// started.
+	programStartTime time.Time
+
+	// connectTime is the time at which lndmon first connected to lnd.
+	// Since lndmon waits for lnd's wallet to be unlocked before
+	// connecting, it is a best-effort timestamp of lnd's first unlock.
+	connectTime time.Time
+
+	// The fields below track lnd's state. They are guarded by stateMtx.
+	stateMtx sync.Mutex
+
+	// state is the label of the state lnd is currently in, or empty
+	// before we learned it.
+	state string
+
+	// since is the time at which lnd entered its current state.
+	since time.Time
+
+	// durations holds the time spent in each state, excluding the
+	// current one.
+	durations map[string]time.Duration
+
+	// startupTime is the time at which we first saw lnd in a state before
+	// unlocking its wallet during its current startup, if any.
+	startupTime time.Time
+
+	// unlockTime is the time at which lnd unlocked its wallet during its
+	// current startup, if we saw it happen.
+	unlockTime time.Time
+
+	// reconnected is true if we've just resubscribed to lnd's state and
+	// are waiting for its first state.
+	reconnected bool
+
+	// firstActiveTime is the time at which we first saw lnd's server
+	// active, or zero if we haven't yet.
+	firstActiveTime time.Time
+
+	// active is closed while lnd's server is active, and inactive is
+	// closed while we know that it isn't. Neither is closed before we
+	// learned lnd's state.
+	active   chan struct{}
+	inactive chan struct{}
+
+	restartCounter   prometheus.Counter
+	reconnectCounter prometheus.Counter
+
+	unlockDuration prometheus.Histogram
+	startDuration  prometheus.Histogram
+
+	stateDesc        *prometheus.Desc
+	stateSecondsDesc *prometheus.Desc
+
+	timeToUnlockDesc *prometheus.Desc
+	timeToStartDesc  *prometheus.Desc
+
+	// cancel cancels the context of our current subscription.
+	cancel context.CancelFunc
+
+	// quit is closed to signal that we need to shutdown.
+	quit chan struct{}
+
+	wg sync.WaitGroup
+}
+
+// A compile time check to ensure that walletStateMonitor implements the
+// prometheus.Collector interface.
+var _ prometheus.Collector = (*walletStateMonitor)(nil)
+
+// newWalletStateMonitor creates a new wallet state monitor.
+func newWalletStateMonitor(lnd *lndclient.LndServices,
+	programStartTime time.Time) *walletStateMonitor {
+
+	return &walletStateMonitor{
+		subscribeState: func(ctx context.Context) (
+			lnrpc.State_SubscribeStateClient, error) {
+
+			// gRPC backs off exponentially while lnd is down,
+			// which could make us miss lnd's startup entirely, so
+			// we ask it to retry right away.
+			lnd.ClientConn.ResetConnectBackoff()
+
+			// We use the raw client since lndclient closes the
+			// subscription once lnd's server is active.
+			rpcCtx, _, client := lnd.State.RawClientWithMacAuth(ctx)
+
+			return client.SubscribeState(
+				rpcCtx, &lnrpc.SubscribeStateRequest{},
+			)
+		},
+		resubscribeInterval: stateResubscribeInterval,
+		programStartTime:    programStartTime,
+		connectTime:         time.Now(),
+		durations:           make(map[string]time.Duration),
+		active:              make(chan struct{}),
+		inactive:            make(chan struct{}),
+		restartCounter: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "lnd",
+			Name:      "restarts_total",
+			Help: "number of times lnd was seen starting up " +
+				"again after we lost our connection to it",
+		}),
+		reconnectCounter: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "lnd",
+			Subsystem: "state",
+			Name:      "reconnects_total",
+			Help: "number of times we resubscribed to lnd's " +
+				"state after losing our connection to it",
+		}),
+		unlockDuration: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Namespace: "lnd",
+				Subsystem: "wallet",
+				Name:      "unlock_duration_seconds",
+				Help: "time from lnd starting up until its " +
+					"wallet was unlocked",
+				Buckets: prometheus.ExponentialBuckets(
+					1, 2, 16,
+				),
+			},
+		),
+		startDuration: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Namespace: "lnd",
+				Subsystem: "wallet",
+				Name:      "start_duration_seconds",
+				Help: "time from lnd's wallet being unlocked " +
+					"until its server was active",
+				Buckets: prometheus.ExponentialBuckets(
+					1, 2, 16,
+				),
+			},
+		),
+		stateDesc: prometheus.NewDesc(
+			"lnd_wallet_state",
+			"whether lnd is currently in this state",
+			[]string{"state"}, nil,
+		),
+		stateSecondsDesc: prometheus.NewDesc(
+			"lnd_wallet_state_seconds_total",
+			"time lnd spent in this state since lndmon was "+
+				"started",
+			[]string{"state"}, nil,
+		),
+		timeToUnlockDesc: prometheus.NewDesc(
+			"lnd_time_to_unlock_millisecs",
+			"time to unlocked in milliseconds",
+			nil, nil,
+		),
+		timeToStartDesc: prometheus.NewDesc(
+			"lnd_time_to_start_millisecs",
+			"time to start in milliseconds",
+			nil, nil,
+		),
+		quit: make(chan struct{}),
+	}
+}
+
+// start subscribes to lnd's state and begins the main loop of the monitor.
+func (w *walletStateMonitor) start() error {
+	Logger.Info("Starting wallet state monitor")
+
+	stream, err := w.subscribe()
+	if err != nil {
+		return err
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+
+		for {
+			err := w.consume(stream)
+
+			select {
+			case <-w.quit:
+				return
+			default:
+			}
+
+			Logger.Infof("Lost wallet state subscription: %v", err)
+			w.processDisconnect(time.Now())
+
+			stream = w.resubscribe()
+			if stream == nil {
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// stop sends the wallet state monitor's goroutine the instruction to shutdown
+// and waits for it to exit.
+func (w *walletStateMonitor) stop() {
+	Logger.Info("Stopping wallet state monitor")
+
+	close(w.quit)
+
+	w.stateMtx.Lock()
+	if w.cancel != nil {
+		w.cancel()
+	}
+	w.stateMtx.Unlock()
+
+	w.wg.Wait()
+}
+
+// collectors returns all of the collectors that the wallet state monitor
+// uses. Since the time spent in the current state depends on the time of the
+// scrape, the monitor itself collects the state metrics.
+func (w *walletStateMonitor) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		w.restartCounter, w.reconnectCounter, w.unlockDuration,
+		w.startDuration, w,
+	}
+}
+
+// lndActive returns a channel that is closed while lnd's server is active.
+func (w *walletStateMonitor) lndActive() <-chan struct{} {
+	w.stateMtx.Lock()
+	defer w.stateMtx.Unlock()
+
+	return w.active
+}
+
+// lndInactive returns a channel that is closed while lnd is unreachable or
+// starting up.
+func (w *walletStateMonitor) lndInactive() <-chan struct{} {
+	w.stateMtx.Lock()
+	defer w.stateMtx.Unlock()
+
+	return w.inactive
+}
+
+// subscribe opens a new subscription to lnd's state.
+func (w *walletStateMonitor) subscribe() (
+	lnrpc.State_SubscribeStateClient, error) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// We register the cancel function before subscribing, so that a
+	// concurrent call to stop always cancels the subscription.
+	w.stateMtx.Lock()
+	select {
+	case <-w.quit:
+		w.stateMtx.Unlock()
+		cancel()
+
+		return nil, errShuttingDown
+
+	default:
+	}
+
+	// Our previous subscription has already failed, but we still need to
+	// release its context.
+	if w.cancel != nil {
+		w.cancel()
+	}
+	w.cancel = cancel
+	w.stateMtx.Unlock()
+
+	stream, err := w.subscribeState(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return stream, nil
+}
+
+// resubscribe tries to subscribe to lnd's state until it succeeds. It returns
+// nil if the monitor is shutting down.
+func (w *walletStateMonitor) resubscribe() lnrpc.State_SubscribeStateClient {
+	ticker := time.NewTicker(w.resubscribeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.quit:
+			return nil
+		}
+
+		stream, err := w.subscribe()
+		if err != nil {
+			Logger.Debugf("Unable to resubscribe to wallet "+
+				"state: %v", err)
+			continue
+		}
+
+		w.stateMtx.Lock()
+		w.reconnected = true
+		w.stateMtx.Unlock()
+		w.reconnectCounter.Inc()
+
+		return stream
+	}
+}
+
+// consume processes the state updates of the given subscription until it
+// fails.
+func (w *walletStateMonitor) consume(
+	stream lnrpc.State_SubscribeStateClient) error {
+
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		w.processState(resp.State, time.Now())
+	}
+}
+
+// processState records lnd entering the given state.
+func (w *walletStateMonitor) processState(state lnrpc.WalletState,
+	now time.Time) {
+
+	w.stateMtx.Lock()
+	defer w.stateMtx.Unlock()
+
+	// If lnd isn't fully up yet when we reconnect to it, it must have
+	// restarted while we weren't connected.
+	if w.reconnected {
+		w.reconnected = false
+
+		if state != lnrpc.WalletState_SERVER_ACTIVE {
+			w.restartCounter.Inc()
+		}
+	}
+
+	w.setState(walletStateLabel(state), now)
+
+	switch state {
+	case lnrpc.WalletState_WAITING_TO_START,
+		lnrpc.WalletState_NON_EXISTING, lnrpc.WalletState_LOCKED:
+
+		if w.startupTime.IsZero() {
+			w.startupTime = now
+		}
+
+	case lnrpc.WalletState_UNLOCKED:
+		if !w.startupTime.IsZero() {
+			w.unlockDuration.Observe(
+				now.Sub(w.startupTime).Seconds(),
+			)
+			w.startupTime = time.Time{}
+		}
+		w.unlockTime = now
+
+	case lnrpc.WalletState_SERVER_ACTIVE:
+		if !w.unlockTime.IsZero() {
+			w.startDuration.Observe(now.Sub(w.unlockTime).Seconds())
+		}
+		w.startupTime = time.Time{}
+		w.unlockTime = time.Time{}
+
+		if w.firstActiveTime.IsZero() {
+			w.firstActiveTime = now
+		}
+	}
+}
+
+// processDisconnect records that we lost our connection to lnd. Any startup
+// that was in progress is abandoned.
+func (w *walletStateMonitor) processDisconnect(now time.Time) {
+	w.stateMtx.Lock()
+	defer w.stateMtx.Unlock()
+
+	w.setState(walletStateUnreachable, now)
+	w.startupTime = time.Time{}
+	w.unlockTime = time.Time{}
+}
+
+// setState moves lnd into the given state, accounting for the time spent in
+// its previous state. The caller must hold stateMtx.
+func (w *walletStateMonitor) setState(state string, now time.Time) {
+	if state == w.state {
+		return
+	}
+
+	if w.state != "" {
+		w.durations[w.state] += now.Sub(w.since)
+	}
+
+	// Signal whether lnd's server is active to anyone waiting for it to
+	// become active or to go away.
+	serverActive := walletStateLabel(lnrpc.WalletState_SERVER_ACTIVE)
+	switch {
+	case state == serverActive:
+		close(w.active)
+		w.inactive = make(chan struct{})
+
+	case w.state == serverActive:
+		w.active = make(chan struct{})
+		close(w.inactive)
+
+	// The first state we learn tells us that lnd isn't active yet.
+	case w.state == "":
+		close(w.inactive)
+	}
+
+	w.state = state
+	w.since = now
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once the
+// last descriptor has been sent.
+//
+// NOTE: Part of the prometheus.Collector interface.
+func (w *walletStateMonitor) Describe(ch chan<- *prometheus.Desc) {
+	ch <- w.stateDesc
+	ch <- w.stateSecondsDesc
+	ch <- w.timeToUnlockDesc
+	ch <- w.timeToStartDesc
+}
+
+// Collect is called by the Prometheus registry when collecting metrics.
+//
+// NOTE: Part of the prometheus.Collector interface.
+func (w *walletStateMonitor) Collect(ch chan<- prometheus.Metric) {
+	now := time.Now()
+
+	w.stateMtx.Lock()
+	defer w.stateMtx.Unlock()
+
+	for _, state := range walletStates {
+		var current float64
+		duration := w.durations[state]
+		if state == w.state {
+			current = 1
+			duration += now.Sub(w.since)
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			w.stateDesc, prometheus.GaugeValue, current, state,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			w.stateSecondsDesc, prometheus.CounterValue,
+			duration.Seconds(), state,
+		)
+	}
+
+	// The durations of lnd's first startup are only known once its server
+	// is active.
+	if w.firstActiveTime.IsZero() {
+		return
+	}
+
+	timeToUnlock := w.connectTime.Sub(w.programStartTime)
+	timeToStart := w.firstActiveTime.Sub(w.connectTime)
+
+	ch <- prometheus.MustNewConstMetric(
+		w.timeToUnlockDesc, prometheus.GaugeValue,
+		float64(timeToUnlock.Milliseconds()),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		w.timeToStartDesc, prometheus.GaugeValue,
+		float64(timeToStart.Milliseconds()),
+	)
+}
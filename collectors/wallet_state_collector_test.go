@@ -0,0 +1,173 @@
+package collectors
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// fakeStateStream is a state subscription that delivers the states we send
+// into it, and fails once we close it.
+type fakeStateStream struct {
+	grpc.ClientStream
+
+	ctx    context.Context
+	states chan lnrpc.WalletState
+}
+
+// Recv returns the next state sent into the stream.
+func (f *fakeStateStream) Recv() (*lnrpc.SubscribeStateResponse, error) {
+	select {
+	case state, ok := <-f.states:
+		if !ok {
+			return nil, errors.New("lnd shutting down")
+		}
+
+		return &lnrpc.SubscribeStateResponse{State: state}, nil
+
+	case <-f.ctx.Done():
+		return nil, f.ctx.Err()
+	}
+}
+
+// newFakeWalletStateMonitor returns a wallet state monitor whose
+// subscriptions are delivered on the returned channel. Every subscription
+// attempt in failAttempts fails as if lnd was down.
+func newFakeWalletStateMonitor(failAttempts ...int) (*walletStateMonitor,
+	chan *fakeStateStream) {
+
+	streams := make(chan *fakeStateStream, 1)
+
+	var attempts int
+	monitor := newWalletStateMonitor(nil, time.Now())
+	monitor.resubscribeInterval = time.Millisecond
+	monitor.subscribeState = func(ctx context.Context) (
+		lnrpc.State_SubscribeStateClient, error) {
+
+		attempts++
+		for _, attempt := range failAttempts {
+			if attempts == attempt {
+				return nil, errors.New("connection refused")
+			}
+		}
+
+		stream := &fakeStateStream{
+			ctx:    ctx,
+			states: make(chan lnrpc.WalletState),
+		}
+		streams <- stream
+
+		return stream, nil
+	}
+
+	return monitor, streams
+}
+
+// requireClosed waits for the given channel to be closed.
+func requireClosed(t *testing.T, ch <-chan struct{}) {
+	t.Helper()
+
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for channel to be closed")
+	}
+}
+
+// TestWalletStateMonitor tests that we track the time lnd spends in each state
+// and detect its restarts.
+func TestWalletStateMonitor(t *testing.T) {
+	var (
+		start   = time.Unix(1_000_000, 0)
+		monitor = newWalletStateMonitor(nil, start.Add(-90*time.Second))
+	)
+	monitor.connectTime = start.Add(-30 * time.Second)
+
+	state := func(state lnrpc.WalletState, offset time.Duration) {
+		monitor.processState(state, start.Add(offset))
+	}
+
+	// lndmon starts up once lnd's server is active. Losing the connection
+	// and finding lnd still active isn't counted as a restart.
+	state(lnrpc.WalletState_SERVER_ACTIVE, 0)
+	monitor.processDisconnect(start.Add(time.Minute))
+	monitor.reconnected = true
+	state(lnrpc.WalletState_SERVER_ACTIVE, 2*time.Minute)
+	require.Zero(t, testutil.ToFloat64(monitor.restartCounter))
+
+	// When lnd comes back still locked, it must have restarted. We then
+	// time how long it took to unlock and start.
+	monitor.processDisconnect(start.Add(time.Hour))
+	monitor.reconnected = true
+	state(lnrpc.WalletState_LOCKED, time.Hour+time.Minute)
+	state(lnrpc.WalletState_UNLOCKED, time.Hour+3*time.Minute)
+	state(lnrpc.WalletState_RPC_ACTIVE, time.Hour+4*time.Minute)
+	state(lnrpc.WalletState_SERVER_ACTIVE, time.Hour+5*time.Minute)
+	require.Equal(t, 1.0, testutil.ToFloat64(monitor.restartCounter))
+
+	require.Equal(t, map[string]time.Duration{
+		"server_active": time.Minute + 58*time.Minute,
+		"unreachable":   2 * time.Minute,
+		"locked":        2 * time.Minute,
+		"unlocked":      time.Minute,
+		"rpc_active":    time.Minute,
+	}, monitor.durations)
+	require.Equal(t, "server_active", monitor.state)
+
+	require.Equal(t, 1, testutil.CollectAndCount(monitor.unlockDuration))
+	require.Equal(t, 1, testutil.CollectAndCount(monitor.startDuration))
+	require.Equal(
+		t, 2*len(walletStates)+2, testutil.CollectAndCount(monitor),
+	)
+
+	// The durations of lnd's first startup are those up to the first time
+	// we saw it active.
+	expected := `
+# HELP lnd_time_to_start_millisecs time to start in milliseconds
+# TYPE lnd_time_to_start_millisecs gauge
+lnd_time_to_start_millisecs 30000
+# HELP lnd_time_to_unlock_millisecs time to unlocked in milliseconds
+# TYPE lnd_time_to_unlock_millisecs gauge
+lnd_time_to_unlock_millisecs 60000
+`
+	require.NoError(t, testutil.CollectAndCompare(
+		monitor, strings.NewReader(expected),
+		"lnd_time_to_start_millisecs", "lnd_time_to_unlock_millisecs",
+	))
+}
+
+// TestWalletStateMonitorReconnect tests that the wallet state monitor
+// resubscribes after lnd went away and detects that lnd restarted.
+func TestWalletStateMonitorReconnect(t *testing.T) {
+	// The first resubscription fails since lnd is still down.
+	monitor, streams := newFakeWalletStateMonitor(2)
+	require.NoError(t, monitor.start())
+	defer monitor.stop()
+
+	stream := <-streams
+	stream.states <- lnrpc.WalletState_SERVER_ACTIVE
+	requireClosed(t, monitor.lndActive())
+
+	// lnd shuts down and comes back up locked.
+	close(stream.states)
+	requireClosed(t, monitor.lndInactive())
+
+	stream = <-streams
+	stream.states <- lnrpc.WalletState_LOCKED
+	stream.states <- lnrpc.WalletState_UNLOCKED
+	stream.states <- lnrpc.WalletState_RPC_ACTIVE
+	stream.states <- lnrpc.WalletState_SERVER_ACTIVE
+	requireClosed(t, monitor.lndActive())
+
+	require.Equal(t, 1.0, testutil.ToFloat64(monitor.reconnectCounter))
+	require.Equal(t, 1.0, testutil.ToFloat64(monitor.restartCounter))
+	require.Equal(t, 1, testutil.CollectAndCount(monitor.unlockDuration))
+	require.Equal(t, 1, testutil.CollectAndCount(monitor.startDuration))
+}
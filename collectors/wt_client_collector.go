@@ -7,9 +7,58 @@ import (
 	"strings"
 
 	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnrpc/wtclientrpc"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+const (
+	wtSessionStatusActive    = "active"
+	wtSessionStatusExhausted = "exhausted"
+
+	// wtNoClientErr is the error lnd returns when querying the policy of
+	// a policy type that it doesn't run a client for.
+	wtNoClientErr = "no client for the given blob type"
+)
+
+// wtPolicyType describes one of the watchtower client's policy types, along
+// with the type of justice blobs its sessions back up.
+type wtPolicyType struct {
+	policyType wtclientrpc.PolicyType
+	label      string
+	blobType   string
+}
+
+// wtPolicyTypes is the set of policy types the watchtower client may run a
+// client for.
+var wtPolicyTypes = []wtPolicyType{
+	{
+		policyType: wtclientrpc.PolicyType_LEGACY,
+		label:      "legacy",
+		blobType:   "altruist_commit",
+	},
+	{
+		policyType: wtclientrpc.PolicyType_ANCHOR,
+		label:      "anchor",
+		blobType:   "altruist_anchor_commit",
+	},
+	{
+		policyType: wtclientrpc.PolicyType_TAPROOT,
+		label:      "taproot",
+		blobType:   "altruist_taproot_commit",
+	},
+}
+
+// wtPolicyTypeLabel returns the label we use for the given policy type.
+func wtPolicyTypeLabel(policyType wtclientrpc.PolicyType) string {
+	for _, wtPolicy := range wtPolicyTypes {
+		if wtPolicy.policyType == policyType {
+			return wtPolicy.label
+		}
+	}
+
+	return strings.ToLower(policyType.String())
+}
+
 // WtClientCollector is a collector that will export watchtower client-related
 // metrics.
 type WtClientCollector struct {
@@ -18,6 +67,28 @@ type WtClientCollector struct {
 	numBackupsDesc        *prometheus.Desc
 	numPendingBackupsDesc *prometheus.Desc
 
+	// The descriptors below describe the sessions of each tower, per
+	// policy type.
+	towerActiveDesc         *prometheus.Desc
+	numSessionsDesc         *prometheus.Desc
+	remainingUpdatesDesc    *prometheus.Desc
+	sessionMaxUpdatesDesc   *prometheus.Desc
+	sessionUsedUpdatesDesc  *prometheus.Desc
+	sessionSweepFeeRateDesc *prometheus.Desc
+
+	// The descriptors below describe the client's active policies.
+	policyInfoDesc         *prometheus.Desc
+	policyMaxUpdatesDesc   *prometheus.Desc
+	policySweepFeeRateDesc *prometheus.Desc
+
+	// The descriptors below describe the client's statistics since lnd
+	// was started.
+	statsBackupsDesc           *prometheus.Desc
+	statsPendingBackupsDesc    *prometheus.Desc
+	statsFailedBackupsDesc     *prometheus.Desc
+	statsSessionsAcquiredDesc  *prometheus.Desc
+	statsSessionsExhaustedDesc *prometheus.Desc
+
 	// errChan is a channel that we send any errors that we encounter into.
 	// This channel should be buffered so that it does not block sending.
 	errChan chan<- error
@@ -27,6 +98,14 @@ type WtClientCollector struct {
 func NewWtClientCollector(lnd *lndclient.LndServices,
 	errChan chan<- error) *WtClientCollector {
 
+	var (
+		policyLabels  = []string{"policy_type"}
+		towerLabels   = []string{"tower_pubkey", "policy_type"}
+		sessionLabels = []string{
+			"tower_pubkey", "policy_type", "session_id",
+		}
+	)
+
 	return &WtClientCollector{
 		lnd: lnd,
 		numBackupsDesc: prometheus.NewDesc(
@@ -44,6 +123,83 @@ func NewWtClientCollector(lnd *lndclient.LndServices,
 			}, nil,
 		),
 
+		towerActiveDesc: prometheus.NewDesc(
+			"lnd_wt_client_tower_active",
+			"whether the tower is a candidate for new sessions",
+			towerLabels, nil,
+		),
+		numSessionsDesc: prometheus.NewDesc(
+			"lnd_wt_client_num_sessions",
+			"number of sessions negotiated with the tower",
+			append(towerLabels, "status"), nil,
+		),
+		remainingUpdatesDesc: prometheus.NewDesc(
+			"lnd_wt_client_remaining_updates",
+			"number of updates left in the active sessions with "+
+				"the tower",
+			towerLabels, nil,
+		),
+		sessionMaxUpdatesDesc: prometheus.NewDesc(
+			"lnd_wt_client_session_max_updates",
+			"max number of updates of an active session",
+			sessionLabels, nil,
+		),
+		sessionUsedUpdatesDesc: prometheus.NewDesc(
+			"lnd_wt_client_session_used_updates",
+			"number of acked and pending updates of an active "+
+				"session",
+			sessionLabels, nil,
+		),
+		sessionSweepFeeRateDesc: prometheus.NewDesc(
+			"lnd_wt_client_session_sweep_sat_per_vbyte",
+			"fee rate of the justice transactions of an active "+
+				"session",
+			sessionLabels, nil,
+		),
+
+		policyInfoDesc: prometheus.NewDesc(
+			"lnd_wt_client_policy_info",
+			"watchtower client policy metadata, always set to 1",
+			[]string{"policy_type", "blob_type"}, nil,
+		),
+		policyMaxUpdatesDesc: prometheus.NewDesc(
+			"lnd_wt_client_policy_max_updates",
+			"max number of updates of new sessions",
+			policyLabels, nil,
+		),
+		policySweepFeeRateDesc: prometheus.NewDesc(
+			"lnd_wt_client_policy_sweep_sat_per_vbyte",
+			"fee rate of the justice transactions of new sessions",
+			policyLabels, nil,
+		),
+
+		statsBackupsDesc: prometheus.NewDesc(
+			"lnd_wt_client_stats_backups_total",
+			"number of backups made to all sessions since startup",
+			nil, nil,
+		),
+		statsPendingBackupsDesc: prometheus.NewDesc(
+			"lnd_wt_client_stats_pending_backups",
+			"number of backups pending to be acked by all sessions",
+			nil, nil,
+		),
+		statsFailedBackupsDesc: prometheus.NewDesc(
+			"lnd_wt_client_stats_failed_backups_total",
+			"number of backups that sessions failed to ack since "+
+				"startup",
+			nil, nil,
+		),
+		statsSessionsAcquiredDesc: prometheus.NewDesc(
+			"lnd_wt_client_stats_sessions_acquired_total",
+			"number of sessions negotiated since startup",
+			nil, nil,
+		),
+		statsSessionsExhaustedDesc: prometheus.NewDesc(
+			"lnd_wt_client_stats_sessions_exhausted_total",
+			"number of sessions exhausted since startup",
+			nil, nil,
+		),
+
 		errChan: errChan,
 	}
 }
@@ -56,6 +212,23 @@ func NewWtClientCollector(lnd *lndclient.LndServices,
 func (c *WtClientCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.numBackupsDesc
 	ch <- c.numPendingBackupsDesc
+
+	ch <- c.towerActiveDesc
+	ch <- c.numSessionsDesc
+	ch <- c.remainingUpdatesDesc
+	ch <- c.sessionMaxUpdatesDesc
+	ch <- c.sessionUsedUpdatesDesc
+	ch <- c.sessionSweepFeeRateDesc
+
+	ch <- c.policyInfoDesc
+	ch <- c.policyMaxUpdatesDesc
+	ch <- c.policySweepFeeRateDesc
+
+	ch <- c.statsBackupsDesc
+	ch <- c.statsPendingBackupsDesc
+	ch <- c.statsFailedBackupsDesc
+	ch <- c.statsSessionsAcquiredDesc
+	ch <- c.statsSessionsExhaustedDesc
 }
 
 // Collect is called by the Prometheus registry when collecting metrics.
@@ -104,5 +277,145 @@ func (c *WtClientCollector) Collect(ch chan<- prometheus.Metric) {
 			c.numPendingBackupsDesc, prometheus.GaugeValue,
 			float64(numPendingBackups), pubkey,
 		)
+
+		for _, sessionInfo := range tower.SessionInfo {
+			c.collectSessionMetrics(ch, pubkey, sessionInfo)
+		}
+	}
+
+	c.collectPolicyMetrics(ch)
+	c.collectStatsMetrics(ch)
+}
+
+// collectSessionMetrics exports the metrics of the sessions we hold with a
+// tower for a single policy type.
+func (c *WtClientCollector) collectSessionMetrics(ch chan<- prometheus.Metric,
+	pubkey string, sessionInfo *wtclientrpc.TowerSessionInfo) {
+
+	if sessionInfo == nil {
+		return
+	}
+
+	policyType := wtPolicyTypeLabel(sessionInfo.PolicyType)
+
+	var active float64
+	if sessionInfo.ActiveSessionCandidate {
+		active = 1
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.towerActiveDesc, prometheus.GaugeValue, active, pubkey,
+		policyType,
+	)
+
+	var numActive, numExhausted, remainingUpdates uint32
+	for _, session := range sessionInfo.Sessions {
+		used := session.NumBackups + session.NumPendingBackups
+		if used >= session.MaxBackups {
+			numExhausted++
+			continue
+		}
+
+		numActive++
+		remainingUpdates += session.MaxBackups - used
+
+		// We only export the details of active sessions, since
+		// exhausted sessions accumulate over time.
+		sessionID := hex.EncodeToString(session.Id)
+		ch <- prometheus.MustNewConstMetric(
+			c.sessionMaxUpdatesDesc, prometheus.GaugeValue,
+			float64(session.MaxBackups), pubkey, policyType,
+			sessionID,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.sessionUsedUpdatesDesc, prometheus.GaugeValue,
+			float64(used), pubkey, policyType, sessionID,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.sessionSweepFeeRateDesc, prometheus.GaugeValue,
+			float64(session.SweepSatPerVbyte), pubkey, policyType,
+			sessionID,
+		)
 	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.numSessionsDesc, prometheus.GaugeValue, float64(numActive),
+		pubkey, policyType, wtSessionStatusActive,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.numSessionsDesc, prometheus.GaugeValue,
+		float64(numExhausted), pubkey, policyType,
+		wtSessionStatusExhausted,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.remainingUpdatesDesc, prometheus.GaugeValue,
+		float64(remainingUpdates), pubkey, policyType,
+	)
+}
+
+// collectPolicyMetrics exports the client's active policy for each policy
+// type that the client runs a client for.
+func (c *WtClientCollector) collectPolicyMetrics(ch chan<- prometheus.Metric) {
+	for _, wtPolicy := range wtPolicyTypes {
+		policy, err := c.lnd.WtClient.Policy(
+			context.Background(), wtPolicy.policyType,
+		)
+		if err != nil {
+			// lnd doesn't run a client for every policy type (e.g.
+			// if taproot channels aren't enabled).
+			if strings.Contains(err.Error(), wtNoClientErr) {
+				watchtowerLogger.Debugf("No watchtower client "+
+					"for policy type %v", wtPolicy.label)
+				continue
+			}
+
+			c.errChan <- fmt.Errorf("WtClientCollector Policy "+
+				"failed with: %v", err)
+			return
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			c.policyInfoDesc, prometheus.GaugeValue, 1,
+			wtPolicy.label, wtPolicy.blobType,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.policyMaxUpdatesDesc, prometheus.GaugeValue,
+			float64(policy.MaxUpdates), wtPolicy.label,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.policySweepFeeRateDesc, prometheus.GaugeValue,
+			float64(policy.SweepSatPerVbyte), wtPolicy.label,
+		)
+	}
+}
+
+// collectStatsMetrics exports the client-wide statistics lnd keeps in memory
+// since it was started.
+func (c *WtClientCollector) collectStatsMetrics(ch chan<- prometheus.Metric) {
+	stats, err := c.lnd.WtClient.Stats(context.Background())
+	if err != nil {
+		c.errChan <- fmt.Errorf("WtClientCollector Stats failed "+
+			"with: %v", err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.statsBackupsDesc, prometheus.CounterValue,
+		float64(stats.NumBackups),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.statsPendingBackupsDesc, prometheus.GaugeValue,
+		float64(stats.NumPendingBackups),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.statsFailedBackupsDesc, prometheus.CounterValue,
+		float64(stats.NumFailedBackups),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.statsSessionsAcquiredDesc, prometheus.CounterValue,
+		float64(stats.NumSessionsAcquired),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.statsSessionsExhaustedDesc, prometheus.CounterValue,
+		float64(stats.NumSessionsExhausted),
+	)
 }
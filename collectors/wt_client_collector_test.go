@@ -0,0 +1,63 @@
+package collectors
+
+import (
+	"testing"
+
+	"github.com/lightningnetwork/lnd/lnrpc/wtclientrpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWtClientSessionMetrics tests that sessions are split into active and
+// exhausted ones, and that only active sessions are exported individually.
+func TestWtClientSessionMetrics(t *testing.T) {
+	collector := NewWtClientCollector(nil, make(chan error, 1))
+
+	sessionInfo := &wtclientrpc.TowerSessionInfo{
+		ActiveSessionCandidate: true,
+		PolicyType:             wtclientrpc.PolicyType_ANCHOR,
+		Sessions: []*wtclientrpc.TowerSession{
+			{
+				NumBackups:        100,
+				NumPendingBackups: 2,
+				MaxBackups:        1024,
+				Id:                []byte{1},
+			},
+			{
+				NumBackups: 1024,
+				MaxBackups: 1024,
+				Id:         []byte{2},
+			},
+		},
+	}
+
+	ch := make(chan prometheus.Metric, 20)
+	collector.collectSessionMetrics(ch, "tower", sessionInfo)
+	close(ch)
+
+	series := collectMetrics(t, ch)
+	for _, descSeries := range series {
+		for _, s := range descSeries {
+			require.Equal(t, "anchor", s.labels["policy_type"])
+		}
+	}
+
+	require.Equal(t, map[string]float64{"tower": 1}, valuesByLabel(
+		series[collector.towerActiveDesc], "tower_pubkey",
+	))
+	require.Equal(t, map[string]float64{
+		"active":    1,
+		"exhausted": 1,
+	}, valuesByLabel(series[collector.numSessionsDesc], "status"))
+	require.Equal(t, map[string]float64{"tower": 922}, valuesByLabel(
+		series[collector.remainingUpdatesDesc], "tower_pubkey",
+	))
+
+	// Only the active session is exported individually.
+	require.Equal(t, map[string]float64{"01": 102}, valuesByLabel(
+		series[collector.sessionUsedUpdatesDesc], "session_id",
+	))
+	require.Equal(t, map[string]float64{"01": 1024}, valuesByLabel(
+		series[collector.sessionMaxUpdatesDesc], "session_id",
+	))
+}
@@ -0,0 +1,153 @@
+package collectors
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnrpc/watchtowerrpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// wtServerNotActiveErr is the error lnd returns when its watchtower server
+// is compiled in but not enabled.
+const wtServerNotActiveErr = "watchtower not active"
+
+// WtServerCollector is a collector that will export watchtower server-related
+// metrics.
+type WtServerCollector struct {
+	lnd    lndclient.LightningClient
+	client watchtowerrpc.WatchtowerClient
+
+	upDesc           *prometheus.Desc
+	infoDesc         *prometheus.Desc
+	listenerInfoDesc *prometheus.Desc
+	uriInfoDesc      *prometheus.Desc
+
+	// errChan is a channel that we send any errors that we encounter into.
+	// This channel should be buffered so that it does not block sending.
+	errChan chan<- error
+}
+
+// NewWtServerCollector returns a new instance of the WtServerCollector.
+func NewWtServerCollector(lnd *lndclient.LndServices,
+	errChan chan<- error) *WtServerCollector {
+
+	collector := &WtServerCollector{
+		upDesc: prometheus.NewDesc(
+			"lnd_wt_server_up",
+			"whether lnd's watchtower server is active",
+			nil, nil,
+		),
+		infoDesc: prometheus.NewDesc(
+			"lnd_wt_server_info",
+			"watchtower server information",
+			[]string{"pubkey"}, nil,
+		),
+		listenerInfoDesc: prometheus.NewDesc(
+			"lnd_wt_server_listener_info",
+			"address the watchtower server is listening on",
+			[]string{"pubkey", "address"}, nil,
+		),
+		uriInfoDesc: prometheus.NewDesc(
+			"lnd_wt_server_uri_info",
+			"uri the watchtower server can be reached at",
+			[]string{"pubkey", "uri"}, nil,
+		),
+		errChan: errChan,
+	}
+
+	if lnd != nil {
+		collector.lnd = lnd.Client
+		collector.client = watchtowerrpc.NewWatchtowerClient(
+			lnd.ClientConn,
+		)
+	}
+
+	return collector
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once the
+// last descriptor has been sent.
+//
+// NOTE: Part of the prometheus.Collector interface.
+func (c *WtServerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.upDesc
+	ch <- c.infoDesc
+	ch <- c.listenerInfoDesc
+	ch <- c.uriInfoDesc
+}
+
+// Collect is called by the Prometheus registry when collecting metrics.
+//
+// NOTE: Part of the prometheus.Collector interface.
+func (c *WtServerCollector) Collect(ch chan<- prometheus.Metric) {
+	// The watchtower RPC uses the same macaroon as the main RPC server,
+	// so we borrow its authenticated context.
+	rpcCtx, timeout, _ := c.lnd.RawClientWithMacAuth(
+		context.Background(),
+	)
+	rpcCtx, cancel := context.WithTimeout(rpcCtx, timeout)
+	defer cancel()
+
+	info, err := c.client.GetInfo(rpcCtx, &watchtowerrpc.GetInfoRequest{})
+	if err != nil {
+		// If the watchtower server is not active, we'll just report it
+		// as down.
+		if wtServerInactive(err) {
+			watchtowerLogger.Debug("Watchtower server not active")
+
+			ch <- prometheus.MustNewConstMetric(
+				c.upDesc, prometheus.GaugeValue, 0,
+			)
+			return
+		}
+
+		c.errChan <- fmt.Errorf("WtServerCollector GetInfo failed "+
+			"with: %v", err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.upDesc, prometheus.GaugeValue, 1)
+	c.collectInfo(ch, info)
+}
+
+// collectInfo exports the information of an active watchtower server.
+func (c *WtServerCollector) collectInfo(ch chan<- prometheus.Metric,
+	info *watchtowerrpc.GetInfoResponse) {
+
+	pubkey := hex.EncodeToString(info.Pubkey)
+
+	ch <- prometheus.MustNewConstMetric(
+		c.infoDesc, prometheus.GaugeValue, 1, pubkey,
+	)
+
+	for _, listener := range info.Listeners {
+		ch <- prometheus.MustNewConstMetric(
+			c.listenerInfoDesc, prometheus.GaugeValue, 1, pubkey,
+			listener,
+		)
+	}
+
+	for _, uri := range info.Uris {
+		ch <- prometheus.MustNewConstMetric(
+			c.uriInfoDesc, prometheus.GaugeValue, 1, pubkey, uri,
+		)
+	}
+}
+
+// wtServerInactive returns true if the error indicates that lnd doesn't run a
+// watchtower server, either because it is disabled or because lnd was built
+// without the watchtowerrpc sub-server.
+func wtServerInactive(err error) bool {
+	if status.Code(err) == codes.Unimplemented {
+		return true
+	}
+
+	return strings.Contains(err.Error(), wtServerNotActiveErr)
+}
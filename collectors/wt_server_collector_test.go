@@ -0,0 +1,40 @@
+package collectors
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lightningnetwork/lnd/lnrpc/watchtowerrpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestWtServerCollector tests that we export the information of an active
+// watchtower server and detect inactive ones.
+func TestWtServerCollector(t *testing.T) {
+	collector := NewWtServerCollector(nil, make(chan error, 1))
+
+	ch := make(chan prometheus.Metric, 10)
+	collector.collectInfo(ch, &watchtowerrpc.GetInfoResponse{
+		Pubkey:    []byte{1, 2},
+		Listeners: []string{"0.0.0.0:9911", "[::]:9911"},
+		Uris:      []string{"0102@1.2.3.4:9911"},
+	})
+	close(ch)
+
+	series := collectMetrics(t, ch)
+	require.Len(t, series, 3)
+	require.Len(t, series[collector.infoDesc], 1)
+	require.Len(t, series[collector.listenerInfoDesc], 2)
+	require.Len(t, series[collector.uriInfoDesc], 1)
+
+	require.True(t, wtServerInactive(
+		status.Error(codes.Unknown, wtServerNotActiveErr),
+	))
+	require.True(t, wtServerInactive(
+		status.Error(codes.Unimplemented, "unknown service"),
+	))
+	require.False(t, wtServerInactive(errors.New("connection refused")))
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/lightninglabs/lndmon/collectors"
+	"github.com/lightningnetwork/lnd/routing/route"
 )
 
 var (
@@ -15,6 +16,18 @@ var (
 
 	// defaultMacaroon is the default macaroon that we use for lndmon.
 	defaultMacaroon = "readonly.macaroon"
+
+	// defaultAdminMacaroon is the default macaroon that we use to bake
+	// lndmon's macaroon.
+	defaultAdminMacaroon = "admin.macaroon"
+
+	// defaultBakedMacaroon is the default name that we save the macaroon
+	// we bake for lndmon under.
+	defaultBakedMacaroon = "lndmon.macaroon"
+
+	// defaultDataDir is the default directory that lndmon persists its
+	// state in.
+	defaultDataDir = btcutil.AppDataDir("lndmon", false)
 )
 
 type lndConfig struct {
@@ -36,6 +49,17 @@ type lndConfig struct {
 
 	// TLSPath is the path to the lnd TLS certificate.
 	TLSPath string `long:"tlspath" description:"Path to lnd tls certificate"`
+
+	// TLSCert is the lnd TLS certificate, given directly rather than as a
+	// file.
+	TLSCert string `long:"tlscert" env:"LNDMON_LND_TLSCERT" description:"lnd tls certificate as PEM, or hex or base64 encoded PEM or DER, instead of tlspath"`
+
+	// Macaroon is our macaroon, given directly rather than as a file.
+	Macaroon string `long:"macaroon" env:"LNDMON_LND_MACAROON" description:"Hex or base64 encoded macaroon to use instead of macaroondir and macaroonname"`
+
+	// ConnectURI is an lndconnect URI that holds the address, TLS
+	// certificate and macaroon to connect to lnd with.
+	ConnectURI string `long:"connecturi" env:"LNDMON_LND_CONNECTURI" description:"lndconnect URI holding the lnd host, tls certificate and macaroon; the system's certificates are used if it has no certificate and tlspath is unset"`
 }
 
 type config struct {
@@ -46,6 +70,24 @@ type config struct {
 	// connect to it.
 	Lnd *lndConfig `group:"lnd" namespace:"lnd"`
 
+	// GraphStats specifies how distributions of values across the channel
+	// graph are exported.
+	GraphStats *collectors.GraphStatsConfig `group:"graph" namespace:"graph"`
+
+	// Centrality specifies how the centrality and reachability metrics of
+	// our node are computed.
+	Centrality *collectors.CentralityConfig `group:"centrality" namespace:"centrality"`
+
+	// FeeEstimates specifies which fee estimates of lnd are exported.
+	FeeEstimates *collectors.FeeEstimatesConfig `group:"feeestimates" namespace:"feeestimates"`
+
+	// Blocks specifies how the blocks lnd is notified of are monitored.
+	Blocks *collectors.BlocksConfig `group:"blocks" namespace:"blocks"`
+
+	// Permissions specifies how the permissions of our macaroon are
+	// checked at startup.
+	Permissions *collectors.PermissionsConfig `group:"permissions" namespace:"permissions"`
+
 	// PrimaryNode is the pubkey of the primary node in primary-gateway setups.
 	PrimaryNode string `long:"primarynode" description:"Public key of the primary node in a primary-gateway setup"`
 
@@ -57,6 +99,24 @@ type config struct {
 
 	// DisablePayments disables the collection of payments metrics.
 	DisablePayments bool `long:"disablepayments" description:"Do not collect payments metrics"`
+
+	// DisablePolicyUpdates disables the collection of channel policy
+	// update metrics.
+	DisablePolicyUpdates bool `long:"disablepolicyupdates" description:"Do not collect channel policy update metrics"`
+
+	// DisableGossip disables the collection of gossip metrics and the
+	// in-memory graph.
+	DisableGossip bool `long:"disablegossip" description:"Do not collect gossip metrics and fetch the full graph from lnd on every scrape instead of maintaining it in memory"`
+
+	// DisablePeerEvents disables the collection of peer connection event
+	// metrics.
+	DisablePeerEvents bool `long:"disablepeerevents" description:"Do not collect peer connection event metrics"`
+
+	// MetricsVersion is the version of metric definitions to export.
+	MetricsVersion int `long:"metricsversion" description:"The version of metric definitions to export, see metrics.md for the changes between versions" choice:"1" choice:"2"`
+
+	// DataDir is the directory that lndmon persists its state in.
+	DataDir string `long:"datadir" description:"Directory to persist lndmon's state in"`
 }
 
 var defaultConfig = config{
@@ -68,8 +128,60 @@ var defaultConfig = config{
 		MacaroonName: defaultMacaroon,
 		RPCTimeout:   30 * time.Second,
 	},
+	GraphStats:     collectors.DefaultGraphStatsConfig(),
+	Centrality:     collectors.DefaultCentralityConfig(),
+	FeeEstimates:   collectors.DefaultFeeEstimatesConfig(),
+	Blocks:         collectors.DefaultBlocksConfig(),
+	Permissions:    collectors.DefaultPermissionsConfig(),
+	MetricsVersion: collectors.MetricsVersion1,
+	DataDir:        defaultDataDir,
 }
 
 var (
 	cfg = defaultConfig
 )
+
+// validate checks that the configuration is sane.
+func (c *config) validate() error {
+	if err := c.GraphStats.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Centrality.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.FeeEstimates.Validate(); err != nil {
+		return err
+	}
+
+	return c.Blocks.Validate()
+}
+
+// monitoringConfig returns the configuration of the collectors that specifies
+// how the node is monitored.
+func (c *config) monitoringConfig() (*collectors.MonitoringConfig, error) {
+	monitoringCfg := &collectors.MonitoringConfig{
+		DisableGraph:         c.DisableGraph,
+		DisableHtlc:          c.DisableHtlc,
+		DisablePayments:      c.DisablePayments,
+		DisablePolicyUpdates: c.DisablePolicyUpdates,
+		DisableGossip:        c.DisableGossip,
+		DisablePeerEvents:    c.DisablePeerEvents,
+		MetricsVersion:       c.MetricsVersion,
+		GraphStats:           c.GraphStats,
+		Centrality:           c.Centrality,
+		FeeEstimates:         c.FeeEstimates,
+		Blocks:               c.Blocks,
+		DataDir:              c.DataDir,
+	}
+	if c.PrimaryNode != "" {
+		primaryNode, err := route.NewVertexFromStr(c.PrimaryNode)
+		if err != nil {
+			return nil, err
+		}
+		monitoringCfg.PrimaryNode = &primaryNode
+	}
+
+	return monitoringCfg, nil
+}
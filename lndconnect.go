@@ -0,0 +1,205 @@
+package lndmon
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// lndConnectScheme is the scheme of lndconnect URIs.
+const lndConnectScheme = "lndconnect"
+
+// lndConnection holds everything we need to connect to lnd. Credentials are
+// either held in memory or read from files.
+type lndConnection struct {
+	// host is the RPC address of lnd.
+	host string
+
+	// tlsPath is the path to lnd's TLS certificate. It is empty if
+	// tlsData is set.
+	tlsPath string
+
+	// tlsData is lnd's PEM encoded TLS certificate, if it was given
+	// directly.
+	tlsData string
+
+	// systemCert is set if lnd's TLS certificate should be verified
+	// using the system's certificate pool.
+	systemCert bool
+
+	// macaroonPath is the path to our macaroon. It is empty if macaroon
+	// is set.
+	macaroonPath string
+
+	// macaroon is our macaroon, if it was given directly.
+	macaroon []byte
+}
+
+// macaroonHex returns our macaroon hex encoded, or an empty string if it is
+// read from a file.
+func (l *lndConnection) macaroonHex() string {
+	return hex.EncodeToString(l.macaroon)
+}
+
+// readMacaroon returns our macaroon, reading it from its file if it wasn't
+// given directly.
+func (l *lndConnection) readMacaroon() ([]byte, error) {
+	if l.macaroon != nil {
+		return l.macaroon, nil
+	}
+
+	return os.ReadFile(l.macaroonPath)
+}
+
+// connection returns how we connect to lnd. An lndconnect URI takes precedence
+// over the host, TLS certificate and macaroon options, and certificates and
+// macaroons given directly take precedence over their files.
+func (c *lndConfig) connection() (*lndConnection, error) {
+	conn := &lndConnection{
+		host:    c.Host,
+		tlsPath: c.TLSPath,
+		macaroonPath: filepath.Join(
+			c.MacaroonDir, c.MacaroonName,
+		),
+	}
+
+	tlsCert, macaroon := c.TLSCert, c.Macaroon
+	if c.ConnectURI != "" {
+		if tlsCert != "" || macaroon != "" {
+			return nil, errors.New("an lndconnect URI can't be " +
+				"combined with a TLS certificate or macaroon")
+		}
+
+		var err error
+		conn.host, tlsCert, macaroon, err = parseLndConnectURI(
+			c.ConnectURI,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// lndconnect URIs leave out the certificate if lnd's
+		// certificate is signed by a certificate authority.
+		if tlsCert == "" && conn.tlsPath == "" {
+			conn.systemCert = true
+		}
+	}
+
+	if tlsCert != "" {
+		if c.TLSPath != "" && c.ConnectURI == "" {
+			return nil, errors.New("only one of a TLS " +
+				"certificate and TLS path can be set")
+		}
+
+		tlsData, err := decodeTLSCert(tlsCert)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLS certificate: %v",
+				err)
+		}
+
+		conn.tlsPath = ""
+		conn.tlsData = tlsData
+	}
+
+	if macaroon != "" {
+		macaroonBytes, err := decodeBinary(macaroon)
+		if err != nil {
+			return nil, fmt.Errorf("invalid macaroon: %v", err)
+		}
+
+		conn.macaroonPath = ""
+		conn.macaroon = macaroonBytes
+	}
+
+	return conn, nil
+}
+
+// parseLndConnectURI parses an lndconnect URI of the form
+// lndconnect://host:port?cert=<cert>&macaroon=<macaroon>. The certificate and
+// macaroon are returned encoded as they were in the URI, and are empty if
+// the URI doesn't contain them.
+func parseLndConnectURI(uri string) (string, string, string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid lndconnect URI: %v", err)
+	}
+
+	if u.Scheme != lndConnectScheme {
+		return "", "", "", fmt.Errorf("invalid lndconnect URI scheme "+
+			"%q", u.Scheme)
+	}
+
+	if u.Host == "" {
+		return "", "", "", errors.New("lndconnect URI has no host")
+	}
+
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid lndconnect URI query: "+
+			"%v", err)
+	}
+
+	// Query values are decoded like form values, which turns the '+' of
+	// standard base64 encodings into spaces, so we restore them.
+	cert := strings.ReplaceAll(query.Get("cert"), " ", "+")
+	macaroon := strings.ReplaceAll(query.Get("macaroon"), " ", "+")
+
+	return u.Host, cert, macaroon, nil
+}
+
+// decodeBinary decodes a hex or base64 encoded value. Both the standard and
+// the URL-safe base64 encodings are accepted, with or without padding.
+func decodeBinary(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+
+	if decoded, err := hex.DecodeString(value); err == nil {
+		return decoded, nil
+	}
+
+	for _, encoding := range []*base64.Encoding{
+		base64.StdEncoding, base64.RawStdEncoding,
+		base64.URLEncoding, base64.RawURLEncoding,
+	} {
+		if decoded, err := encoding.DecodeString(value); err == nil {
+			return decoded, nil
+		}
+	}
+
+	return nil, errors.New("neither hex nor base64 encoded")
+}
+
+// decodeTLSCert returns the given TLS certificate PEM encoded. It can be
+// given PEM encoded, or as a hex or base64 encoding of either its PEM or DER
+// encoding, the latter being used by lndconnect URIs.
+func decodeTLSCert(cert string) (string, error) {
+	pemPrefix := "-----BEGIN"
+	if strings.HasPrefix(strings.TrimSpace(cert), pemPrefix) {
+		return cert, nil
+	}
+
+	decoded, err := decodeBinary(cert)
+	if err != nil {
+		return "", err
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(decoded), []byte(pemPrefix)) {
+		return string(decoded), nil
+	}
+
+	if _, err := x509.ParseCertificate(decoded); err != nil {
+		return "", err
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: decoded,
+	})), nil
+}
@@ -0,0 +1,155 @@
+package lndmon
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testCert returns the DER encoding of a self-signed certificate.
+func testCert(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{Organization: []string{"lnd"}},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(
+		rand.Reader, template, template, &key.PublicKey, key,
+	)
+	require.NoError(t, err)
+
+	return der
+}
+
+// TestDecodeTLSCert tests that we accept TLS certificates in all the
+// encodings we support and return them PEM encoded.
+func TestDecodeTLSCert(t *testing.T) {
+	der := testCert(t)
+	certPEM := string(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: der,
+	}))
+
+	for _, cert := range []string{
+		certPEM,
+		base64.StdEncoding.EncodeToString([]byte(certPEM)),
+		base64.RawURLEncoding.EncodeToString(der),
+		base64.StdEncoding.EncodeToString(der),
+		hex.EncodeToString(der),
+	} {
+		decoded, err := decodeTLSCert(cert)
+		require.NoError(t, err)
+		require.Equal(t, certPEM, decoded)
+	}
+
+	_, err := decodeTLSCert(base64.StdEncoding.EncodeToString([]byte("x")))
+	require.Error(t, err)
+}
+
+// TestConnection tests that we connect to lnd with the credentials of an
+// lndconnect URI, or those given directly, instead of files.
+func TestConnection(t *testing.T) {
+	der := testCert(t)
+	macaroon := []byte{0x02, 0x01, 0x03, 0x6c, 0x6e, 0x64}
+
+	base := lndConfig{
+		Host:         "localhost:10009",
+		MacaroonDir:  "/macaroons",
+		MacaroonName: "readonly.macaroon",
+	}
+
+	// Without any credentials given directly we read them from files.
+	cfg := base
+	cfg.TLSPath = "/tls.cert"
+	conn, err := cfg.connection()
+	require.NoError(t, err)
+	require.Equal(t, &lndConnection{
+		host:         "localhost:10009",
+		tlsPath:      "/tls.cert",
+		macaroonPath: "/macaroons/readonly.macaroon",
+	}, conn)
+	require.Empty(t, conn.macaroonHex())
+
+	// An lndconnect URI overrides the host, certificate and macaroon.
+	cfg = base
+	cfg.TLSPath = "/tls.cert"
+	cfg.ConnectURI = "lndconnect://lnd.example.com:10009?cert=" +
+		base64.RawURLEncoding.EncodeToString(der) + "&macaroon=" +
+		base64.RawURLEncoding.EncodeToString(macaroon)
+	conn, err = cfg.connection()
+	require.NoError(t, err)
+	require.Equal(t, "lnd.example.com:10009", conn.host)
+	require.Empty(t, conn.tlsPath)
+	require.NotEmpty(t, conn.tlsData)
+	require.False(t, conn.systemCert)
+	require.Empty(t, conn.macaroonPath)
+	require.Equal(t, hex.EncodeToString(macaroon), conn.macaroonHex())
+
+	readMacaroon, err := conn.readMacaroon()
+	require.NoError(t, err)
+	require.Equal(t, macaroon, readMacaroon)
+
+	// Without a certificate in the URI we use the system's certificates,
+	// unless a TLS path is set.
+	cfg = base
+	cfg.ConnectURI = "lndconnect://lnd.example.com:10009?macaroon=" +
+		hex.EncodeToString(macaroon)
+	conn, err = cfg.connection()
+	require.NoError(t, err)
+	require.True(t, conn.systemCert)
+	require.Equal(t, hex.EncodeToString(macaroon), conn.macaroonHex())
+
+	cfg.TLSPath = "/tls.cert"
+	conn, err = cfg.connection()
+	require.NoError(t, err)
+	require.False(t, conn.systemCert)
+	require.Equal(t, "/tls.cert", conn.tlsPath)
+
+	// Credentials can also be given directly.
+	cfg = base
+	cfg.TLSCert = base64.StdEncoding.EncodeToString(der)
+	cfg.Macaroon = base64.StdEncoding.EncodeToString(macaroon)
+	conn, err = cfg.connection()
+	require.NoError(t, err)
+	require.Equal(t, "localhost:10009", conn.host)
+	require.NotEmpty(t, conn.tlsData)
+	require.Equal(t, hex.EncodeToString(macaroon), conn.macaroonHex())
+
+	// Conflicting options and invalid URIs are rejected.
+	cfg.TLSPath = "/tls.cert"
+	_, err = cfg.connection()
+	require.Error(t, err)
+
+	cfg = base
+	cfg.Macaroon = hex.EncodeToString(macaroon)
+	cfg.ConnectURI = "lndconnect://lnd.example.com:10009"
+	_, err = cfg.connection()
+	require.Error(t, err)
+
+	for _, uri := range []string{
+		"https://lnd.example.com:10009",
+		"lndconnect://?macaroon=00",
+		"lndconnect://lnd.example.com:10009?macaroon=%%",
+		"lndconnect://lnd.example.com:10009?cert=!!",
+	} {
+		cfg = base
+		cfg.ConnectURI = uri
+		_, err = cfg.connection()
+		require.Error(t, err, uri)
+	}
+}
@@ -1,16 +1,16 @@
 package lndmon
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 	"time"
 
 	flags "github.com/jessevdk/go-flags"
 	"github.com/lightninglabs/lndclient"
 	"github.com/lightninglabs/lndmon/collectors"
 	"github.com/lightningnetwork/lnd/lnrpc/verrpc"
-	"github.com/lightningnetwork/lnd/routing/route"
 	"github.com/lightningnetwork/lnd/signal"
 )
 
@@ -25,13 +25,45 @@ func Main() {
 }
 
 func start() error {
-	if _, err := flags.Parse(&cfg); err != nil {
+	parser := flags.NewParser(&cfg, flags.Default)
+	parser.SubcommandsOptional = true
+
+	_, err := parser.AddCommand(
+		"bakemacaroon", "Bake a macaroon with the permissions that "+
+			"lndmon needs",
+		"Bake a macaroon that only has the permissions lndmon needs "+
+			"to run the collectors enabled in its configuration, "+
+			"and save it to the macaroon directory.",
+		&bakeMacaroonCommand{
+			MacaroonName: defaultBakedMacaroon,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err := parser.Parse(); err != nil {
 		if e, ok := err.(*flags.Error); ok && e.Type == flags.ErrHelp {
 			return nil
 		}
 		return err
 	}
 
+	// Commands are executed while parsing, so there's nothing left to do
+	// if one was given.
+	if parser.Active != nil {
+		return nil
+	}
+
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	conn, err := cfg.Lnd.connection()
+	if err != nil {
+		return err
+	}
+
 	quit := make(chan struct{})
 	interceptor, err := signal.Intercept()
 	if err != nil {
@@ -43,13 +75,14 @@ func start() error {
 	// Initialize our lnd client, requiring at least lnd v0.11.
 	lnd, err := lndclient.NewLndServices(
 		&lndclient.LndServicesConfig{
-			LndAddress: cfg.Lnd.Host,
-			Network:    lndclient.Network(cfg.Lnd.Network),
-			CustomMacaroonPath: filepath.Join(
-				cfg.Lnd.MacaroonDir, cfg.Lnd.MacaroonName,
-			),
-			RPCTimeout: cfg.Lnd.RPCTimeout,
-			TLSPath:    cfg.Lnd.TLSPath,
+			LndAddress:         conn.host,
+			Network:            lndclient.Network(cfg.Lnd.Network),
+			CustomMacaroonPath: conn.macaroonPath,
+			CustomMacaroonHex:  conn.macaroonHex(),
+			RPCTimeout:         cfg.Lnd.RPCTimeout,
+			TLSPath:            conn.tlsPath,
+			TLSData:            conn.tlsData,
+			SystemCert:         conn.systemCert,
 			CheckVersion: &verrpc.Version{
 				AppMajor: 0,
 				AppMinor: 13,
@@ -62,24 +95,25 @@ func start() error {
 	}
 	defer lnd.Close()
 
-	monitoringCfg := collectors.MonitoringConfig{
-		DisableGraph:    cfg.DisableGraph,
-		DisableHtlc:     cfg.DisableHtlc,
-		DisablePayments: cfg.DisablePayments,
+	monitoringCfg, err := cfg.monitoringConfig()
+	if err != nil {
+		return err
 	}
-	if cfg.PrimaryNode != "" {
-		primaryNode, err := route.NewVertexFromStr(cfg.PrimaryNode)
+	monitoringCfg.ProgramStartTime = programStartTime
+
+	if !cfg.Permissions.SkipCheck {
+		err := checkPermissions(
+			&lnd.LndServices, conn, monitoringCfg,
+		)
 		if err != nil {
 			return err
 		}
-		monitoringCfg.PrimaryNode = &primaryNode
 	}
-	monitoringCfg.ProgramStartTime = programStartTime
 
 	// Start our Prometheus exporter. This exporter spawns a goroutine
 	// that pulls metrics from our lnd client on a set interval.
 	exporter := collectors.NewPrometheusExporter(
-		cfg.Prometheus, &lnd.LndServices, &monitoringCfg, quit,
+		cfg.Prometheus, &lnd.LndServices, monitoringCfg, quit,
 	)
 	if err := exporter.Start(); err != nil {
 		return err
@@ -103,3 +137,60 @@ func start() error {
 
 	return stopErr
 }
+
+// checkPermissions checks whether our macaroon has the permissions that the
+// enabled collectors need and prints a report of the result. Collectors that
+// lack permissions are either disabled or fail our startup, depending on our
+// configuration.
+func checkPermissions(lnd *lndclient.LndServices, conn *lndConnection,
+	monitoringCfg *collectors.MonitoringConfig) error {
+
+	macaroon, err := conn.readMacaroon()
+	if err != nil {
+		return fmt.Errorf("unable to read macaroon: %v", err)
+	}
+
+	results, err := collectors.CheckPermissions(
+		context.Background(), lnd.Client, macaroon, monitoringCfg,
+	)
+	if errors.Is(err, collectors.ErrPermissionCheckUnsupported) {
+		fmt.Printf("Skipping macaroon permission check: %v\n", err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("macaroon permission check failed: %v", err)
+	}
+
+	fmt.Println("Macaroon permission check:")
+
+	var missing []string
+	for _, result := range results {
+		fmt.Printf("  %v\n", result.String())
+
+		if len(result.Missing) == 0 {
+			continue
+		}
+		missing = append(missing, result.Name)
+
+		if cfg.Permissions.OnMissing ==
+			collectors.PermissionsOnMissingDisable {
+
+			result.Disable(monitoringCfg)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if cfg.Permissions.OnMissing == collectors.PermissionsOnMissingFail {
+		return fmt.Errorf("macaroon lacks permissions for collectors "+
+			"%v, bake a macaroon with the missing permissions or "+
+			"set --permissions.onmissing=disable to disable them",
+			missing)
+	}
+
+	fmt.Printf("Disabled collectors lacking permissions: %v\n", missing)
+
+	return nil
+}